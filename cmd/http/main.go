@@ -1,8 +1,10 @@
 package main
 
 import (
+	"context"
 	"net"
 	"net/http"
+	"time"
 	"github.com/AlifAcademy/TodoList/config"
 	"github.com/AlifAcademy/TodoList/internal/db/postgres"
 	"github.com/AlifAcademy/TodoList/internal/logger"
@@ -10,6 +12,7 @@ import (
 	"github.com/gorilla/mux"
 	"github.com/AlifAcademy/TodoList/internal/service"
 	"github.com/AlifAcademy/TodoList/internal/service/security"
+	"github.com/AlifAcademy/TodoList/internal/storage"
 
 	_ "github.com/jackc/pgx/v4/stdlib"
 	"go.uber.org/dig"
@@ -30,6 +33,7 @@ func main() {
 		serverInit,
 		service.NewService,
 		security.NewService,
+		storage.NewBlobStore,
 	}
 	
 	container := dig.New()
@@ -49,6 +53,19 @@ func main() {
 		log.Error(err)
 		return 
 	}
+
+	err = container.Invoke(func(svc *service.Service) {
+		go svc.RunSnoozeWaker(context.Background(), time.Minute)
+		go svc.RunWebhookDispatcher(context.Background(), 5*time.Second)
+		go svc.RunChangeListener(context.Background())
+		go svc.RunChangePruner(context.Background(), time.Hour)
+		go svc.RunBlobSweeper(context.Background(), time.Hour)
+	})
+
+	if err != nil {
+		log.Error(err)
+		return
+	}
 	
 	
 	container.Invoke(func(server *http.Server) error {
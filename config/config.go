@@ -13,6 +13,7 @@ type Config interface {
 	GetString(key string) string
 	IsSet(key string) bool
 	GetInt(key string) int64
+	GetStringSlice(key string) []string
 }
 
 type config struct {
@@ -51,3 +52,7 @@ func (c *config) IsSet(key string) bool {
 func (c *config) GetInt(key string) int64 {
 	return c.cfg.GetInt64(key)
 }
+
+func (c *config) GetStringSlice(key string) []string {
+	return c.cfg.GetStringSlice(key)
+}
@@ -30,7 +30,20 @@ func NewPostgresDB(cfg config.Config) (*pgxpool.Pool, error) {
 		DBName:   cfg.GetString("db.db_name"),
 		SSLMode:  "disable",
 	}
-	db, err := pgxpool.Connect(context.TODO(), configDb.GenerateDSN())
+	poolConfig, err := pgxpool.ParseConfig(configDb.GenerateDSN())
+	if err != nil {
+		return nil, err
+	}
+	// todo.search_language picks the text search configuration used by the search triggers and queries
+	if language := cfg.GetString("search.language"); language != "" {
+		poolConfig.ConnConfig.RuntimeParams["todo.search_language"] = language
+	}
+	// how close a word must be for the fuzzy <% matches on titles and tag names
+	if threshold := cfg.GetString("search.similarity_threshold"); threshold != "" {
+		poolConfig.ConnConfig.RuntimeParams["pg_trgm.word_similarity_threshold"] = threshold
+	}
+
+	db, err := pgxpool.ConnectConfig(context.TODO(), poolConfig)
 	if err != nil {
 		return nil, err
 	}
@@ -40,8 +53,22 @@ func NewPostgresDB(cfg config.Config) (*pgxpool.Pool, error) {
 		return nil, err
 	}
 
+	// a configuration PostgreSQL does not know would only fail later, in every search trigger
+	if language := cfg.GetString("search.language"); language != "" {
+		_, err = db.Exec(context.TODO(), `SELECT $1::TEXT::regconfig;`, language)
+		if err != nil {
+			db.Close()
+			return nil, fmt.Errorf("invalid search.language %q: %w", language, err)
+		}
+	}
+
 	log.Println("Start seeder table create ")
-	Seeder(db)
+	// every query relies on the migrated schema, so the server does not start without it
+	err = Seeder(db)
+	if err != nil {
+		db.Close()
+		return nil, err
+	}
 
 	return db, nil
 }
@@ -70,6 +97,13 @@ func Seeder(db *pgxpool.Pool) error {
 	  log.Println("the Comments table exists")
 	}
 
+	for i, migration := range Migrations {
+		_, err = db.Exec(context.TODO(), migration)
+		if err != nil {
+			return fmt.Errorf("migration %d failed: %w", i+1, err)
+		}
+	}
+
 	statuses := []models.Status{
 		{ID: 1, Name: "Completed", CodeName: "completed"},
 		{ID: 2, Name: "Cancel", CodeName: "cancel"},
@@ -18,7 +18,6 @@ const (
 		id SERIAL PRIMARY KEY,
 		title VARCHAR(255) NOT NULL,
 		description TEXT,
-		tags TEXT[],
 		status_id INT NOT NULL REFERENCES status(id) ON DELETE CASCADE,
 		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
 		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
@@ -32,4 +31,369 @@ const (
 		task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
 		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE
 	  );`
-)
\ No newline at end of file
+
+	AlterTableTasksVersion = `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`
+
+	AlterTableCommentsVersion = `ALTER TABLE comments ADD COLUMN IF NOT EXISTS version INT NOT NULL DEFAULT 1;`
+
+	CreateTableProjects = `CREATE TABLE IF NOT EXISTS projects (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+	  );`
+
+	AlterTableTasksProject = `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS project_id INT REFERENCES projects(id) ON DELETE SET NULL;`
+
+	AlterTableTasksPriority = `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS priority INT NOT NULL DEFAULT 0;`
+
+	AlterTableTasksDueAt = `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS due_at TIMESTAMP;`
+
+	AlterTableTasksParent = `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS parent_id INT REFERENCES tasks(id) ON DELETE CASCADE;`
+
+	CreateTableTemplates = `CREATE TABLE IF NOT EXISTS templates (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		title VARCHAR(255) NOT NULL,
+		description TEXT,
+		tags TEXT[],
+		due_in_days INT,
+		subtasks JSONB NOT NULL DEFAULT '[]',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+	  );`
+
+	CreateTableTimeEntries = `CREATE TABLE IF NOT EXISTS time_entries (
+		id SERIAL PRIMARY KEY,
+		task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		started_at TIMESTAMP NOT NULL,
+		ended_at TIMESTAMP,
+		note TEXT NOT NULL DEFAULT ''
+	  );`
+
+	CreateIndexTimeEntriesRunning = `CREATE UNIQUE INDEX IF NOT EXISTS time_entries_running_idx ON time_entries (user_id) WHERE ended_at IS NULL;`
+
+	CreateTableAttachments = `CREATE TABLE IF NOT EXISTS attachments (
+		id SERIAL PRIMARY KEY,
+		task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		comment_id INT REFERENCES comments(id) ON DELETE CASCADE,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		filename TEXT NOT NULL,
+		content_type TEXT NOT NULL,
+		size BIGINT NOT NULL,
+		sha256 CHAR(64) NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	  );`
+
+	CreateIndexAttachmentsHash = `CREATE INDEX IF NOT EXISTS attachments_sha256_idx ON attachments (sha256);`
+
+	CreateTableChecklistItems = `CREATE TABLE IF NOT EXISTS checklist_items (
+		id SERIAL PRIMARY KEY,
+		task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		text TEXT NOT NULL,
+		checked BOOLEAN NOT NULL DEFAULT FALSE,
+		position INT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	  );`
+
+	AlterTableTasksSnoozedUntil = `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS snoozed_until TIMESTAMP;`
+
+	CreateIndexTasksSnoozedUntil = `CREATE INDEX IF NOT EXISTS tasks_snoozed_until_idx ON tasks (snoozed_until) WHERE snoozed_until IS NOT NULL;`
+
+	CreateTableEvents = `CREATE TABLE IF NOT EXISTS events (
+		id BIGSERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		type TEXT NOT NULL,
+		task_id INT,
+		payload JSONB NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	  );`
+
+	CreateIndexEventsUser = `CREATE INDEX IF NOT EXISTS events_user_id_idx ON events (user_id, id);`
+
+	CreateTableTags = `CREATE TABLE IF NOT EXISTS tags (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		color TEXT NOT NULL DEFAULT '',
+		description TEXT NOT NULL DEFAULT '',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE (user_id, name)
+	  );`
+
+	CreateTableTaskTags = `CREATE TABLE IF NOT EXISTS task_tags (
+		task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		tag_id INT NOT NULL REFERENCES tags(id) ON DELETE CASCADE,
+		PRIMARY KEY (task_id, tag_id)
+	  );`
+
+	CreateIndexTaskTagsTag = `CREATE INDEX IF NOT EXISTS task_tags_tag_id_idx ON task_tags (tag_id);`
+
+	// MigrateTaskTags moves the old tasks.tags arrays into tags and task_tags once, then drops the column
+	MigrateTaskTags = `DO $$
+	BEGIN
+		IF EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name='tasks' AND column_name='tags') THEN
+			INSERT INTO tags (user_id, name)
+				SELECT DISTINCT t.user_id, lower(btrim(tag)) FROM tasks t, unnest(t.tags) tag WHERE btrim(tag) <> ''
+				ON CONFLICT (user_id, name) DO NOTHING;
+			INSERT INTO task_tags (task_id, tag_id)
+				SELECT DISTINCT t.id, tg.id FROM tasks t, unnest(t.tags) tag, tags tg WHERE tg.user_id=t.user_id AND tg.name=lower(btrim(tag))
+				ON CONFLICT DO NOTHING;
+			ALTER TABLE tasks DROP COLUMN tags;
+		END IF;
+	END $$;`
+
+	// CreateFunctionSearchLanguage returns the text search configuration set per connection in todo.search_language
+	CreateFunctionSearchLanguage = `CREATE OR REPLACE FUNCTION todo_search_language() RETURNS regconfig AS $$
+		SELECT COALESCE(NULLIF(current_setting('todo.search_language', true), ''), 'english')::regconfig;
+	$$ LANGUAGE sql STABLE;`
+
+	AlterTableTasksSearchVector = `ALTER TABLE tasks ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;`
+
+	AlterTableCommentsSearchVector = `ALTER TABLE comments ADD COLUMN IF NOT EXISTS search_vector TSVECTOR;`
+
+	CreateFunctionTaskSearch = `CREATE OR REPLACE FUNCTION todo_task_search() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector :=
+			setweight(to_tsvector(todo_search_language(), COALESCE(NEW.title, '')), 'A') ||
+			setweight(to_tsvector(todo_search_language(), COALESCE((SELECT string_agg(tg.name, ' ') FROM task_tags tt INNER JOIN tags tg ON tg.id=tt.tag_id WHERE tt.task_id=NEW.id), '')), 'B') ||
+			setweight(to_tsvector(todo_search_language(), COALESCE(NEW.description, '')), 'C');
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql;`
+
+	// CreateFunctionTaskTagsSearch refreshes the task's vector when its tags change
+	CreateFunctionTaskTagsSearch = `CREATE OR REPLACE FUNCTION todo_task_tags_search() RETURNS trigger AS $$
+	BEGIN
+		IF TG_OP = 'DELETE' THEN
+			UPDATE tasks SET search_vector=NULL WHERE id=OLD.task_id;
+		ELSE
+			UPDATE tasks SET search_vector=NULL WHERE id=NEW.task_id;
+		END IF;
+		RETURN NULL;
+	END
+	$$ LANGUAGE plpgsql;`
+
+	CreateFunctionCommentSearch = `CREATE OR REPLACE FUNCTION todo_comment_search() RETURNS trigger AS $$
+	BEGIN
+		NEW.search_vector := to_tsvector(todo_search_language(), COALESCE(NEW.content, ''));
+		RETURN NEW;
+	END
+	$$ LANGUAGE plpgsql;`
+
+	CreateTriggersSearch = `DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname='tasks_search_vector') THEN
+			CREATE TRIGGER tasks_search_vector BEFORE INSERT OR UPDATE ON tasks FOR EACH ROW EXECUTE FUNCTION todo_task_search();
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname='task_tags_search_vector') THEN
+			CREATE TRIGGER task_tags_search_vector AFTER INSERT OR DELETE ON task_tags FOR EACH ROW EXECUTE FUNCTION todo_task_tags_search();
+		END IF;
+		IF NOT EXISTS (SELECT 1 FROM pg_trigger WHERE tgname='comments_search_vector') THEN
+			CREATE TRIGGER comments_search_vector BEFORE INSERT OR UPDATE OF content ON comments FOR EACH ROW EXECUTE FUNCTION todo_comment_search();
+		END IF;
+	END $$;`
+
+	// BackfillSearchVectors fills the vectors of rows written before the triggers existed
+	BackfillSearchVectors = `DO $$
+	BEGIN
+		UPDATE tasks SET search_vector=NULL WHERE search_vector IS NULL;
+		UPDATE comments SET content=content WHERE search_vector IS NULL;
+	END $$;`
+
+	CreateIndexTasksSearch = `CREATE INDEX IF NOT EXISTS tasks_search_vector_idx ON tasks USING GIN (search_vector);`
+
+	CreateIndexCommentsSearch = `CREATE INDEX IF NOT EXISTS comments_search_vector_idx ON comments USING GIN (search_vector);`
+
+	CreateExtensionTrgm = `CREATE EXTENSION IF NOT EXISTS pg_trgm;`
+
+	CreateIndexTasksTitleTrgm = `CREATE INDEX IF NOT EXISTS tasks_title_trgm_idx ON tasks USING GIN (title gin_trgm_ops);`
+
+	CreateIndexTagsNameTrgm = `CREATE INDEX IF NOT EXISTS tags_name_trgm_idx ON tags USING GIN (name gin_trgm_ops);`
+
+	CreateTableViews = `CREATE TABLE IF NOT EXISTS views (
+		id SERIAL PRIMARY KEY,
+		name TEXT NOT NULL,
+		filter TEXT NOT NULL DEFAULT '',
+		sort TEXT NOT NULL DEFAULT '',
+		columns TEXT[] NOT NULL DEFAULT '{}',
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		UNIQUE (user_id, name)
+	  );`
+
+	AlterTableCommentsParent = `ALTER TABLE comments ADD COLUMN IF NOT EXISTS parent_comment_id INT REFERENCES comments(id);`
+
+	AlterTableCommentsDeletedAt = `ALTER TABLE comments ADD COLUMN IF NOT EXISTS deleted_at TIMESTAMP;`
+
+	CreateIndexCommentsParent = `CREATE INDEX IF NOT EXISTS comments_parent_comment_id_idx ON comments (parent_comment_id);`
+
+	CreateTableCommentMentions = `CREATE TABLE IF NOT EXISTS comment_mentions (
+		comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (comment_id, user_id)
+	  );`
+
+	CreateIndexCommentMentionsUser = `CREATE INDEX IF NOT EXISTS comment_mentions_user_id_idx ON comment_mentions (user_id);`
+
+	CreateTableTaskReactions = `CREATE TABLE IF NOT EXISTS task_reactions (
+		task_id INT NOT NULL REFERENCES tasks(id) ON DELETE CASCADE,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		emoji TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (task_id, user_id, emoji)
+	  );`
+
+	CreateTableCommentReactions = `CREATE TABLE IF NOT EXISTS comment_reactions (
+		comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		emoji TEXT NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		PRIMARY KEY (comment_id, user_id, emoji)
+	  );`
+
+	AlterTableCommentsUpdatedAt = `DO $$
+	BEGIN
+		IF NOT EXISTS (SELECT 1 FROM information_schema.columns WHERE table_name='comments' AND column_name='updated_at') THEN
+			ALTER TABLE comments ADD COLUMN updated_at TIMESTAMP;
+			UPDATE comments SET updated_at=created_at;
+			ALTER TABLE comments ALTER COLUMN updated_at SET NOT NULL, ALTER COLUMN updated_at SET DEFAULT NOW();
+		END IF;
+	END $$;`
+
+	CreateTableCommentEdits = `CREATE TABLE IF NOT EXISTS comment_edits (
+		id SERIAL PRIMARY KEY,
+		comment_id INT NOT NULL REFERENCES comments(id) ON DELETE CASCADE,
+		content TEXT NOT NULL,
+		version INT NOT NULL,
+		edited_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+	  );`
+
+	CreateIndexCommentEditsComment = `CREATE INDEX IF NOT EXISTS comment_edits_comment_id_idx ON comment_edits (comment_id);`
+
+	CreateTableWebhooks = `CREATE TABLE IF NOT EXISTS webhooks (
+		id SERIAL PRIMARY KEY,
+		url TEXT NOT NULL,
+		secret TEXT NOT NULL,
+		events TEXT[] NOT NULL,
+		project_id INT REFERENCES projects(id) ON DELETE CASCADE,
+		active BOOLEAN NOT NULL DEFAULT TRUE,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		updated_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE
+	  );`
+
+	CreateTableWebhookDeliveries = `CREATE TABLE IF NOT EXISTS webhook_deliveries (
+		id SERIAL PRIMARY KEY,
+		webhook_id INT NOT NULL REFERENCES webhooks(id) ON DELETE CASCADE,
+		event_type TEXT NOT NULL,
+		payload JSONB NOT NULL,
+		status TEXT NOT NULL DEFAULT 'pending',
+		attempts INT NOT NULL DEFAULT 0,
+		next_attempt_at TIMESTAMP NOT NULL DEFAULT NOW(),
+		locked_until TIMESTAMP,
+		delivered_at TIMESTAMP,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	  );`
+
+	CreateIndexWebhookDeliveriesDue = `CREATE INDEX IF NOT EXISTS webhook_deliveries_due_idx ON webhook_deliveries (next_attempt_at) WHERE status = 'pending';`
+
+	CreateIndexWebhookDeliveriesWebhook = `CREATE INDEX IF NOT EXISTS webhook_deliveries_webhook_id_idx ON webhook_deliveries (webhook_id, id);`
+
+	CreateTableWebhookAttempts = `CREATE TABLE IF NOT EXISTS webhook_attempts (
+		id SERIAL PRIMARY KEY,
+		delivery_id INT NOT NULL REFERENCES webhook_deliveries(id) ON DELETE CASCADE,
+		status_code INT,
+		error TEXT NOT NULL DEFAULT '',
+		response_body TEXT NOT NULL DEFAULT '',
+		duration_ms INT NOT NULL DEFAULT 0,
+		attempted_at TIMESTAMP NOT NULL DEFAULT NOW()
+	  );`
+
+	CreateIndexWebhookAttemptsDelivery = `CREATE INDEX IF NOT EXISTS webhook_attempts_delivery_id_idx ON webhook_attempts (delivery_id);`
+
+	CreateTableChanges = `CREATE TABLE IF NOT EXISTS changes (
+		id BIGSERIAL PRIMARY KEY,
+		user_id INT NOT NULL REFERENCES users(id) ON DELETE CASCADE,
+		type TEXT NOT NULL,
+		task_id INT NOT NULL,
+		project_id INT,
+		payload JSONB NOT NULL,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	  );`
+
+	CreateIndexChangesUser = `CREATE INDEX IF NOT EXISTS changes_user_id_idx ON changes (user_id, id);`
+
+	CreateIndexChangesCreatedAt = `CREATE INDEX IF NOT EXISTS changes_created_at_idx ON changes (created_at);`
+
+	CreateTableBlobs = `CREATE TABLE IF NOT EXISTS blobs (
+		sha256 CHAR(64) PRIMARY KEY,
+		created_at TIMESTAMP NOT NULL DEFAULT NOW()
+	  );`
+
+	BackfillBlobs = `INSERT INTO blobs (sha256) SELECT DISTINCT sha256 FROM attachments ON CONFLICT DO NOTHING;`
+)
+
+// Migrations are applied in order on every start, so each one must be idempotent
+var Migrations = []string{
+	AlterTableTasksVersion,
+	AlterTableCommentsVersion,
+	CreateTableProjects,
+	AlterTableTasksProject,
+	AlterTableTasksPriority,
+	AlterTableTasksDueAt,
+	AlterTableTasksParent,
+	CreateTableTemplates,
+	CreateTableTimeEntries,
+	CreateIndexTimeEntriesRunning,
+	CreateTableAttachments,
+	CreateIndexAttachmentsHash,
+	CreateTableChecklistItems,
+	AlterTableTasksSnoozedUntil,
+	CreateIndexTasksSnoozedUntil,
+	CreateTableEvents,
+	CreateIndexEventsUser,
+	CreateTableTags,
+	CreateTableTaskTags,
+	CreateIndexTaskTagsTag,
+	MigrateTaskTags,
+	CreateFunctionSearchLanguage,
+	AlterTableTasksSearchVector,
+	AlterTableCommentsSearchVector,
+	CreateFunctionTaskSearch,
+	CreateFunctionTaskTagsSearch,
+	CreateFunctionCommentSearch,
+	CreateTriggersSearch,
+	BackfillSearchVectors,
+	CreateIndexTasksSearch,
+	CreateIndexCommentsSearch,
+	CreateExtensionTrgm,
+	CreateIndexTasksTitleTrgm,
+	CreateIndexTagsNameTrgm,
+	CreateTableViews,
+	AlterTableCommentsParent,
+	AlterTableCommentsDeletedAt,
+	CreateIndexCommentsParent,
+	CreateTableCommentMentions,
+	CreateIndexCommentMentionsUser,
+	CreateTableTaskReactions,
+	CreateTableCommentReactions,
+	AlterTableCommentsUpdatedAt,
+	CreateTableCommentEdits,
+	CreateIndexCommentEditsComment,
+	CreateTableWebhooks,
+	CreateTableWebhookDeliveries,
+	CreateIndexWebhookDeliveriesDue,
+	CreateIndexWebhookDeliveriesWebhook,
+	CreateTableWebhookAttempts,
+	CreateIndexWebhookAttemptsDelivery,
+	CreateTableChanges,
+	CreateIndexChangesUser,
+	CreateIndexChangesCreatedAt,
+	CreateTableBlobs,
+	BackfillBlobs,
+}
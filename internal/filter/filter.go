@@ -0,0 +1,268 @@
+package filter
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// ErrSyntax if the filter expression cannot be parsed
+var ErrSyntax = errors.New("invalid filter")
+
+// Node is an element of a parsed filter expression
+type Node interface {
+	node()
+}
+
+// And matches when every node matches
+type And struct {
+	Nodes []Node
+}
+
+// Or matches when any node matches
+type Or struct {
+	Nodes []Node
+}
+
+// Not matches when its node does not
+type Not struct {
+	Node Node
+}
+
+// Text is a free text search term
+type Text struct {
+	Value string
+}
+
+// Term compares a field with one or more values; several values match if any of them does
+type Term struct {
+	Field  string
+	Op     string
+	Values []Value
+}
+
+// Value is a validated term value. Dates cover [From, To), instants have From == To
+type Value struct {
+	Text string
+	None bool
+	Int  int64
+	From time.Time
+	To   time.Time
+}
+
+func (*And) node()  {}
+func (*Or) node()   {}
+func (*Not) node()  {}
+func (*Text) node() {}
+func (*Term) node() {}
+
+// Fields
+const (
+	FieldStatus   = "status"
+	FieldTag      = "tag"
+	FieldProject  = "project"
+	FieldPriority = "priority"
+	FieldDue      = "due"
+	FieldCreated  = "created"
+	FieldUpdated  = "updated"
+)
+
+// Operators
+const (
+	OpEq = ":"
+	OpLt = "<"
+	OpLe = "<="
+	OpGt = ">"
+	OpGe = ">="
+)
+
+const (
+	kindName = iota
+	kindID
+	kindPriority
+	kindDate
+)
+
+// fields is the whitelist of filterable fields and the kind of values they take
+var fields = map[string]int{
+	FieldStatus:   kindName,
+	FieldTag:      kindName,
+	FieldProject:  kindID,
+	FieldPriority: kindPriority,
+	FieldDue:      kindDate,
+	FieldCreated:  kindDate,
+	FieldUpdated:  kindDate,
+}
+
+// Priorities by name, in the order of models' priority constants
+var priorities = map[string]int64{
+	"none":   0,
+	"low":    1,
+	"medium": 2,
+	"high":   3,
+	"urgent": 4,
+}
+
+// NewTerm validates a field, operator and values and builds the term, relative dates are resolved against now
+func NewTerm(field string, op string, values []string, now time.Time) (*Term, error) {
+	field = strings.ToLower(field)
+	kind, ok := fields[field]
+	if !ok {
+		return nil, fmt.Errorf("%w: unknown field %q", ErrSyntax, field)
+	}
+	if op == "=" {
+		op = OpEq
+	}
+	if op != OpEq && (kind == kindName || kind == kindID) {
+		return nil, fmt.Errorf("%w: %s only supports %s", ErrSyntax, field, OpEq)
+	}
+	if len(values) == 0 {
+		return nil, fmt.Errorf("%w: %s needs a value", ErrSyntax, field)
+	}
+	if op != OpEq && len(values) > 1 {
+		return nil, fmt.Errorf("%w: %s%s takes a single value", ErrSyntax, field, op)
+	}
+
+	term := &Term{Field: field, Op: op}
+	for _, raw := range values {
+		value, err := parseValue(kind, raw, now)
+		if err != nil {
+			return nil, fmt.Errorf("%w: %s: %v", ErrSyntax, field, err)
+		}
+		if value.None && op != OpEq {
+			return nil, fmt.Errorf("%w: %s%snone is not comparable", ErrSyntax, field, op)
+		}
+		term.Values = append(term.Values, value)
+	}
+	return term, nil
+}
+
+func parseValue(kind int, raw string, now time.Time) (Value, error) {
+	text := strings.ToLower(strings.TrimSpace(raw))
+	if text == "" {
+		return Value{}, errors.New("empty value")
+	}
+	value := Value{Text: text}
+
+	switch kind {
+	case kindID:
+		if text == "none" {
+			value.None = true
+			return value, nil
+		}
+		id, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || id <= 0 {
+			return value, fmt.Errorf("invalid id %q", raw)
+		}
+		value.Int = id
+	case kindPriority:
+		if level, ok := priorities[text]; ok {
+			value.Int = level
+			return value, nil
+		}
+		level, err := strconv.ParseInt(text, 10, 64)
+		if err != nil || level < 0 || level > priorities["urgent"] {
+			return value, fmt.Errorf("invalid priority %q", raw)
+		}
+		value.Int = level
+	case kindDate:
+		if text == "none" {
+			value.None = true
+			return value, nil
+		}
+		from, to, err := parseDate(text, now)
+		if err != nil {
+			return value, err
+		}
+		value.From, value.To = from, to
+	}
+	return value, nil
+}
+
+// parseDate accepts YYYY-MM-DD, RFC 3339, now, today, tomorrow, yesterday and offsets like +3d, -2w or +12h
+func parseDate(text string, now time.Time) (time.Time, time.Time, error) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	day := func(offset int) (time.Time, time.Time, error) {
+		start := today.AddDate(0, 0, offset)
+		return start, start.AddDate(0, 0, 1), nil
+	}
+
+	switch text {
+	case "now":
+		return now, now, nil
+	case "today":
+		return day(0)
+	case "tomorrow":
+		return day(1)
+	case "yesterday":
+		return day(-1)
+	}
+
+	if start, err := time.ParseInLocation("2006-01-02", text, now.Location()); err == nil {
+		return start, start.AddDate(0, 0, 1), nil
+	}
+	if instant, err := time.Parse(time.RFC3339, strings.ToUpper(text)); err == nil {
+		instant = instant.In(now.Location())
+		return instant, instant, nil
+	}
+
+	if len(text) >= 3 && (text[0] == '+' || text[0] == '-') {
+		n, err := strconv.Atoi(text[1 : len(text)-1])
+		if err == nil {
+			if text[0] == '-' {
+				n = -n
+			}
+			switch text[len(text)-1] {
+			case 'd':
+				return day(n)
+			case 'w':
+				return day(7 * n)
+			case 'h':
+				instant := now.Add(time.Duration(n) * time.Hour)
+				return instant, instant, nil
+			}
+		}
+	}
+	return time.Time{}, time.Time{}, fmt.Errorf("invalid date %q", text)
+}
+
+// Join combines nodes with AND, skipping nil ones; it returns nil when nothing is left
+func Join(nodes ...Node) Node {
+	and := &And{}
+	for _, node := range nodes {
+		if node != nil {
+			and.Nodes = append(and.Nodes, node)
+		}
+	}
+	switch len(and.Nodes) {
+	case 0:
+		return nil
+	case 1:
+		return and.Nodes[0]
+	}
+	return and
+}
+
+// Texts returns the free text values of the expression that are not negated
+func Texts(node Node) []string {
+	texts := make([]string, 0)
+	var walk func(Node)
+	walk = func(node Node) {
+		switch n := node.(type) {
+		case *And:
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *Or:
+			for _, child := range n.Nodes {
+				walk(child)
+			}
+		case *Text:
+			texts = append(texts, n.Value)
+		}
+	}
+	walk(node)
+	return texts
+}
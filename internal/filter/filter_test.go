@@ -0,0 +1,104 @@
+package filter
+
+import (
+	"testing"
+	"time"
+)
+
+func TestParseDate(t *testing.T) {
+	zone := testNow.Location()
+	day := func(y int, m time.Month, d int) time.Time {
+		return time.Date(y, m, d, 0, 0, 0, 0, zone)
+	}
+
+	tests := []struct {
+		text string
+		from time.Time
+		to   time.Time
+	}{
+		{"now", testNow, testNow},
+		{"today", day(2026, 10, 19), day(2026, 10, 20)},
+		{"tomorrow", day(2026, 10, 20), day(2026, 10, 21)},
+		{"yesterday", day(2026, 10, 18), day(2026, 10, 19)},
+		{"+3d", day(2026, 10, 22), day(2026, 10, 23)},
+		{"-1d", day(2026, 10, 18), day(2026, 10, 19)},
+		{"+0d", day(2026, 10, 19), day(2026, 10, 20)},
+		{"-2w", day(2026, 10, 5), day(2026, 10, 6)},
+		{"+1w", day(2026, 10, 26), day(2026, 10, 27)},
+		{"+14d", day(2026, 11, 2), day(2026, 11, 3)},
+		{"+12h", testNow.Add(12 * time.Hour), testNow.Add(12 * time.Hour)},
+		{"-36h", testNow.Add(-36 * time.Hour), testNow.Add(-36 * time.Hour)},
+		{"2026-11-01", day(2026, 11, 1), day(2026, 11, 2)},
+		{"2026-12-31", day(2026, 12, 31), day(2027, 1, 1)},
+		{"2026-11-01t10:00:00z", time.Date(2026, 11, 1, 13, 0, 0, 0, zone), time.Date(2026, 11, 1, 13, 0, 0, 0, zone)},
+		{"2026-11-01t10:00:00+03:00", time.Date(2026, 11, 1, 10, 0, 0, 0, zone), time.Date(2026, 11, 1, 10, 0, 0, 0, zone)},
+	}
+
+	for _, test := range tests {
+		from, to, err := parseDate(test.text, testNow)
+		if err != nil {
+			t.Errorf("parseDate(%q) error: %v", test.text, err)
+			continue
+		}
+		if !from.Equal(test.from) || !to.Equal(test.to) {
+			t.Errorf("parseDate(%q) = [%v, %v), want [%v, %v)", test.text, from, to, test.from, test.to)
+		}
+		if from.Location() != zone {
+			t.Errorf("parseDate(%q) is in %v, want the location of now", test.text, from.Location())
+		}
+	}
+}
+
+func TestParseDateInvalid(t *testing.T) {
+	for _, text := range []string{"", "soon", "+d", "3d", "+3", "+3x", "+xd", "2026-13-01", "2026-02-30", "2026/11/01", "2026-11-01t25:00:00z"} {
+		if from, to, err := parseDate(text, testNow); err == nil {
+			t.Errorf("parseDate(%q) = [%v, %v), want an error", text, from, to)
+		}
+	}
+}
+
+func TestNewTermValues(t *testing.T) {
+	term, err := NewTerm("Priority", "=", []string{"urgent", " 2 ", "None"}, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if term.Field != FieldPriority || term.Op != OpEq {
+		t.Fatalf("term = %s%s", term.Field, term.Op)
+	}
+	want := []int64{4, 2, 0}
+	for i, value := range term.Values {
+		if value.Int != want[i] {
+			t.Errorf("value %d = %d, want %d", i, value.Int, want[i])
+		}
+	}
+
+	term, err = NewTerm("due", OpEq, []string{"none"}, testNow)
+	if err != nil || !term.Values[0].None {
+		t.Fatalf("due:none = %+v, %v", term, err)
+	}
+}
+
+func TestJoinAndTexts(t *testing.T) {
+	if Join(nil, nil) != nil {
+		t.Error("joining nothing is not nil")
+	}
+	single := &Text{Value: "a"}
+	if Join(nil, single) != single {
+		t.Error("joining one node does not return it")
+	}
+
+	node, err := Parse(`alpha (beta OR -gamma) NOT delta tag:x "epsilon zeta"`, testNow)
+	if err != nil {
+		t.Fatal(err)
+	}
+	texts := Texts(Join(node, &Text{Value: "eta"}))
+	want := []string{"alpha", "beta", "epsilon zeta", "eta"}
+	if len(texts) != len(want) {
+		t.Fatalf("Texts = %q, want %q", texts, want)
+	}
+	for i := range want {
+		if texts[i] != want[i] {
+			t.Fatalf("Texts = %q, want %q", texts, want)
+		}
+	}
+}
@@ -0,0 +1,304 @@
+package filter
+
+import (
+	"fmt"
+	"strings"
+	"time"
+)
+
+// MaxLength is the longest filter expression Parse accepts
+const MaxLength = 1000
+
+// maxDepth limits nesting of parentheses and negations
+const maxDepth = 32
+
+const (
+	tokLParen = iota
+	tokRParen
+	tokNot
+	tokAnd
+	tokOr
+	tokText
+	tokTerm
+)
+
+type token struct {
+	kind int
+	pos  int
+	text string
+	term *Term
+}
+
+// Parse turns an expression like `status:new,in_progress tag:work -tag:blocked due<2026-11-01 "free text"`
+// into an AST. Terms next to each other are joined with AND; AND, OR, NOT and parentheses combine them
+// explicitly, and a leading - negates a term. An empty expression parses to nil.
+func Parse(input string, now time.Time) (Node, error) {
+	if len(input) > MaxLength {
+		return nil, fmt.Errorf("%w: longer than %d characters", ErrSyntax, MaxLength)
+	}
+	tokens, err := lex(input, now)
+	if err != nil {
+		return nil, err
+	}
+	if len(tokens) == 0 {
+		return nil, nil
+	}
+
+	p := &parser{tokens: tokens}
+	node, err := p.parseOr(0)
+	if err != nil {
+		return nil, err
+	}
+	if p.pos < len(p.tokens) {
+		return nil, p.unexpected()
+	}
+	return node, nil
+}
+
+func isSpace(c byte) bool {
+	return c == ' ' || c == '\t' || c == '\n' || c == '\r'
+}
+
+func isDelim(c byte) bool {
+	return isSpace(c) || c == '(' || c == ')' || c == '"'
+}
+
+func isOp(c byte) bool {
+	return c == ':' || c == '<' || c == '>' || c == '='
+}
+
+func isFieldName(word string) bool {
+	if word == "" {
+		return false
+	}
+	for i := 0; i < len(word); i++ {
+		c := word[i] | 0x20
+		if c < 'a' || c > 'z' {
+			return false
+		}
+	}
+	return true
+}
+
+func lex(input string, now time.Time) ([]token, error) {
+	tokens := make([]token, 0)
+	i := 0
+	for i < len(input) {
+		c := input[i]
+		switch {
+		case isSpace(c):
+			i++
+		case c == '(':
+			tokens = append(tokens, token{kind: tokLParen, pos: i})
+			i++
+		case c == ')':
+			tokens = append(tokens, token{kind: tokRParen, pos: i})
+			i++
+		case c == '-' && i+1 < len(input) && !isSpace(input[i+1]):
+			tokens = append(tokens, token{kind: tokNot, pos: i})
+			i++
+		case c == '"':
+			text, next, err := readQuoted(input, i)
+			if err != nil {
+				return nil, err
+			}
+			tokens = append(tokens, token{kind: tokText, pos: i, text: text})
+			i = next
+		default:
+			start := i
+			for i < len(input) && !isDelim(input[i]) && !isOp(input[i]) {
+				i++
+			}
+			word := input[start:i]
+
+			if i < len(input) && isOp(input[i]) && isFieldName(word) {
+				term, next, err := readTerm(input, word, i, now)
+				if err != nil {
+					return nil, err
+				}
+				tokens = append(tokens, token{kind: tokTerm, pos: start, term: term})
+				i = next
+				continue
+			}
+
+			for i < len(input) && !isDelim(input[i]) {
+				i++
+			}
+			word = input[start:i]
+			switch word {
+			case "AND":
+				tokens = append(tokens, token{kind: tokAnd, pos: start})
+			case "OR":
+				tokens = append(tokens, token{kind: tokOr, pos: start})
+			case "NOT":
+				tokens = append(tokens, token{kind: tokNot, pos: start})
+			default:
+				tokens = append(tokens, token{kind: tokText, pos: start, text: word})
+			}
+		}
+	}
+	return tokens, nil
+}
+
+// readQuoted reads a double-quoted string starting at i, \" and \\ are escapes
+func readQuoted(input string, i int) (string, int, error) {
+	var b strings.Builder
+	for j := i + 1; j < len(input); j++ {
+		switch input[j] {
+		case '\\':
+			if j+1 < len(input) {
+				j++
+				b.WriteByte(input[j])
+			}
+		case '"':
+			return b.String(), j + 1, nil
+		default:
+			b.WriteByte(input[j])
+		}
+	}
+	return "", 0, fmt.Errorf("%w: unterminated quote at %d", ErrSyntax, i)
+}
+
+// readTerm reads the operator and the comma separated values following a field name
+func readTerm(input string, field string, i int, now time.Time) (*Term, int, error) {
+	op := input[i : i+1]
+	i++
+	if i < len(input) && input[i] == '=' && (op == OpLt || op == OpGt) {
+		op += "="
+		i++
+	}
+
+	values := make([]string, 0, 1)
+	for {
+		if i < len(input) && input[i] == '"' {
+			value, next, err := readQuoted(input, i)
+			if err != nil {
+				return nil, 0, err
+			}
+			values = append(values, value)
+			i = next
+		} else {
+			start := i
+			for i < len(input) && !isDelim(input[i]) && input[i] != ',' {
+				i++
+			}
+			if i == start {
+				return nil, 0, fmt.Errorf("%w: missing value for %s at %d", ErrSyntax, field, start)
+			}
+			values = append(values, input[start:i])
+		}
+		if i < len(input) && input[i] == ',' {
+			i++
+			continue
+		}
+		break
+	}
+
+	term, err := NewTerm(field, op, values, now)
+	if err != nil {
+		return nil, 0, err
+	}
+	return term, i, nil
+}
+
+type parser struct {
+	tokens []token
+	pos    int
+}
+
+func (p *parser) peek() *token {
+	if p.pos < len(p.tokens) {
+		return &p.tokens[p.pos]
+	}
+	return nil
+}
+
+func (p *parser) unexpected() error {
+	tok := p.peek()
+	if tok == nil {
+		return fmt.Errorf("%w: unexpected end of expression", ErrSyntax)
+	}
+	return fmt.Errorf("%w: unexpected token at %d", ErrSyntax, tok.pos)
+}
+
+func (p *parser) parseOr(depth int) (Node, error) {
+	node, err := p.parseAnd(depth)
+	if err != nil {
+		return nil, err
+	}
+	or := &Or{Nodes: []Node{node}}
+	for tok := p.peek(); tok != nil && tok.kind == tokOr; tok = p.peek() {
+		p.pos++
+		node, err := p.parseAnd(depth)
+		if err != nil {
+			return nil, err
+		}
+		or.Nodes = append(or.Nodes, node)
+	}
+	if len(or.Nodes) == 1 {
+		return or.Nodes[0], nil
+	}
+	return or, nil
+}
+
+func (p *parser) parseAnd(depth int) (Node, error) {
+	node, err := p.parseUnary(depth)
+	if err != nil {
+		return nil, err
+	}
+	and := &And{Nodes: []Node{node}}
+	for tok := p.peek(); tok != nil; tok = p.peek() {
+		if tok.kind == tokAnd {
+			p.pos++
+		} else if tok.kind == tokOr || tok.kind == tokRParen {
+			break
+		}
+		node, err := p.parseUnary(depth)
+		if err != nil {
+			return nil, err
+		}
+		and.Nodes = append(and.Nodes, node)
+	}
+	if len(and.Nodes) == 1 {
+		return and.Nodes[0], nil
+	}
+	return and, nil
+}
+
+func (p *parser) parseUnary(depth int) (Node, error) {
+	if depth > maxDepth {
+		return nil, fmt.Errorf("%w: nested too deeply", ErrSyntax)
+	}
+	tok := p.peek()
+	if tok == nil {
+		return nil, p.unexpected()
+	}
+
+	switch tok.kind {
+	case tokNot:
+		p.pos++
+		node, err := p.parseUnary(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		return &Not{Node: node}, nil
+	case tokLParen:
+		p.pos++
+		node, err := p.parseOr(depth + 1)
+		if err != nil {
+			return nil, err
+		}
+		if tok := p.peek(); tok == nil || tok.kind != tokRParen {
+			return nil, p.unexpected()
+		}
+		p.pos++
+		return node, nil
+	case tokText:
+		p.pos++
+		return &Text{Value: tok.text}, nil
+	case tokTerm:
+		p.pos++
+		return tok.term, nil
+	}
+	return nil, p.unexpected()
+}
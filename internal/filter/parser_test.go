@@ -0,0 +1,156 @@
+package filter
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 19, 15, 30, 0, 0, time.FixedZone("UTC+3", 3*60*60))
+
+// show prints an AST compactly, e.g. (tag:work AND NOT "draft")
+func show(node Node) string {
+	switch n := node.(type) {
+	case nil:
+		return "<nil>"
+	case *And:
+		return "(" + showAll(n.Nodes, " AND ") + ")"
+	case *Or:
+		return "(" + showAll(n.Nodes, " OR ") + ")"
+	case *Not:
+		return "NOT " + show(n.Node)
+	case *Text:
+		return `"` + n.Value + `"`
+	case *Term:
+		values := make([]string, 0, len(n.Values))
+		for _, value := range n.Values {
+			values = append(values, value.Text)
+		}
+		return n.Field + n.Op + strings.Join(values, ",")
+	}
+	return "?"
+}
+
+func showAll(nodes []Node, sep string) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		parts = append(parts, show(node))
+	}
+	return strings.Join(parts, sep)
+}
+
+func TestParse(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{``, `<nil>`},
+		{`   `, `<nil>`},
+		{`report`, `"report"`},
+		{`a b`, `("a" AND "b")`},
+		{`a AND b`, `("a" AND "b")`},
+		{`a OR b`, `("a" OR "b")`},
+		{`a OR b c`, `("a" OR ("b" AND "c"))`},
+		{`a b OR c`, `(("a" AND "b") OR "c")`},
+		{`a AND b OR c AND d`, `(("a" AND "b") OR ("c" AND "d"))`},
+		{`(a OR b) c`, `(("a" OR "b") AND "c")`},
+		{`a (b OR (c d))`, `("a" AND ("b" OR ("c" AND "d")))`},
+		{`-tag:blocked`, `NOT tag:blocked`},
+		{`NOT a`, `NOT "a"`},
+		{`NOT NOT a`, `NOT NOT "a"`},
+		{`-(a OR b)`, `NOT ("a" OR "b")`},
+		{`a -b`, `("a" AND NOT "b")`},
+		{`NOT a OR b`, `(NOT "a" OR "b")`},
+		{`well-known`, `"well-known"`},
+		{`or and not`, `("or" AND "and" AND "not")`},
+		{`"free text"`, `"free text"`},
+		{`"say \"hi\" \\ bye"`, `"say "hi" \ bye"`},
+		{`tag:"two words"`, `tag:two words`},
+		{`status:new,in_progress`, `status:new,in_progress`},
+		{`tag:Work,"Side Project"`, `tag:work,side project`},
+		{`Status:NEW`, `status:new`},
+		{`priority>=high`, `priority>=high`},
+		{`priority<2`, `priority<2`},
+		{`due<=today`, `due<=today`},
+		{`due=none`, `due:none`},
+		{`project:none`, `project:none`},
+		{`status:new tag:work -tag:blocked due<2026-11-01 "free text"`,
+			`(status:new AND tag:work AND NOT tag:blocked AND due<2026-11-01 AND "free text")`},
+		{`"http://example.com"`, `"http://example.com"`},
+	}
+
+	for _, test := range tests {
+		node, err := Parse(test.input, testNow)
+		if err != nil {
+			t.Errorf("Parse(%q) error: %v", test.input, err)
+			continue
+		}
+		if got := show(node); got != test.want {
+			t.Errorf("Parse(%q) = %s, want %s", test.input, got, test.want)
+		}
+	}
+}
+
+func TestParseErrors(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{`(a`, "unexpected end"},
+		{`a)`, "unexpected token at 1"},
+		{`()`, "unexpected token at 1"},
+		{`OR a`, "unexpected token at 0"},
+		{`a OR`, "unexpected end"},
+		{`a AND`, "unexpected end"},
+		{`NOT`, "unexpected end"},
+		{`"open`, "unterminated quote at 0"},
+		{`tag:"open`, "unterminated quote at 4"},
+		{`tag:`, "missing value for tag"},
+		{`tag:a,`, "missing value for tag"},
+		{`colour:red`, `unknown field "colour"`},
+		{`http://example.com`, `unknown field "http"`},
+		{`tag<work`, "tag only supports :"},
+		{`project>2`, "project only supports :"},
+		{`project:abc`, `invalid id "abc"`},
+		{`project:0`, `invalid id "0"`},
+		{`priority:9`, `invalid priority "9"`},
+		{`priority:-1`, `invalid priority "-1"`},
+		{`due<none`, "due<none is not comparable"},
+		{`due<today,tomorrow`, "due< takes a single value"},
+		{`due:soon`, `invalid date "soon"`},
+		{strings.Repeat("(", maxDepth+1) + "a" + strings.Repeat(")", maxDepth+1), "nested too deeply"},
+		{strings.Repeat("NOT ", maxDepth+1) + "a", "nested too deeply"},
+		{strings.Repeat("-", maxDepth+1) + "a", "nested too deeply"},
+		{strings.Repeat("a", MaxLength+1), "longer than 1000 characters"},
+	}
+
+	for _, test := range tests {
+		node, err := Parse(test.input, testNow)
+		if err == nil {
+			t.Errorf("Parse(%.40q) = %s, want an error", test.input, show(node))
+			continue
+		}
+		if !errors.Is(err, ErrSyntax) {
+			t.Errorf("Parse(%.40q) error %v does not wrap ErrSyntax", test.input, err)
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("Parse(%.40q) error %q, want it to mention %q", test.input, err, test.want)
+		}
+	}
+}
+
+func TestParseLimits(t *testing.T) {
+	deepest := strings.Repeat("(", maxDepth) + "a" + strings.Repeat(")", maxDepth)
+	if _, err := Parse(deepest, testNow); err != nil {
+		t.Errorf("nesting %d levels deep: %v", maxDepth, err)
+	}
+	negated := strings.Repeat("NOT ", maxDepth) + "a"
+	if _, err := Parse(negated, testNow); err != nil {
+		t.Errorf("negating %d times: %v", maxDepth, err)
+	}
+	longest := strings.Repeat("a", MaxLength)
+	if _, err := Parse(longest, testNow); err != nil {
+		t.Errorf("%d characters: %v", MaxLength, err)
+	}
+}
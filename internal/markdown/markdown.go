@@ -0,0 +1,42 @@
+package markdown
+
+import (
+	"bytes"
+	"github.com/microcosm-cc/bluemonday"
+	"github.com/yuin/goldmark"
+	"github.com/yuin/goldmark/extension"
+	"regexp"
+)
+
+// md renders CommonMark with task list checkboxes and autolinked URLs. Raw HTML in the source is dropped by
+// goldmark already, the policy below is what actually keeps scripts out of the output
+var md = goldmark.New(
+	goldmark.WithExtensions(extension.TaskList, extension.Linkify),
+)
+
+// policy is the allow-list of elements and attributes the rendered HTML may keep
+var policy = newPolicy()
+
+func newPolicy() *bluemonday.Policy {
+	p := bluemonday.UGCPolicy()
+	p.AllowAttrs("type").Matching(regexp.MustCompile(`^checkbox$`)).OnElements("input")
+	p.AllowAttrs("checked", "disabled").Matching(regexp.MustCompile(`^(|checked|disabled)$`)).OnElements("input")
+	p.AllowURLSchemes("http", "https", "mailto")
+	p.RequireNoFollowOnLinks(true)
+	p.AddTargetBlankToFullyQualifiedLinks(true)
+	return p
+}
+
+// Render converts markdown source to sanitized HTML, safe to insert into a page as is
+func Render(source string) string {
+	if source == "" {
+		return ""
+	}
+
+	var buf bytes.Buffer
+	err := md.Convert([]byte(source), &buf)
+	if err != nil {
+		return policy.Sanitize(source)
+	}
+	return policy.Sanitize(buf.String())
+}
@@ -0,0 +1,69 @@
+package markdown
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestRender(t *testing.T) {
+	tests := []struct {
+		source string
+		want   string
+	}{
+		{"", ""},
+		{"**bold** and _em_", "<p><strong>bold</strong> and <em>em</em></p>\n"},
+		{"- [x] done\n- [ ] open", "<ul>\n<li><input checked=\"\" disabled=\"\" type=\"checkbox\"> done</li>\n<li><input disabled=\"\" type=\"checkbox\"> open</li>\n</ul>\n"},
+		{"see https://example.com", "<p>see <a href=\"https://example.com\" rel=\"nofollow noopener\" target=\"_blank\">https://example.com</a></p>\n"},
+		{"<script>alert(1)</script>", "\n"},
+		{"<img src=x onerror=alert(1)>", "\n"},
+		{"[x](javascript:alert(1))", "<p>x</p>\n"},
+		{"<input type=text>", "\n"},
+		{"a <script>alert(1)</script> b", "<p>a alert(1) b</p>\n"},
+	}
+
+	for _, test := range tests {
+		if got := Render(test.source); got != test.want {
+			t.Errorf("Render(%q) = %q, want %q", test.source, got, test.want)
+		}
+	}
+}
+
+// TestPolicy feeds the HTML straight to the sanitizer, in case the renderer ever starts passing raw HTML through
+func TestPolicy(t *testing.T) {
+	tests := []struct {
+		html string
+		want string
+	}{
+		{`<script>alert(1)</script><p>ok</p>`, `<p>ok</p>`},
+		{`<img src="x.png" onerror="alert(1)">`, `<img src="x.png">`},
+		{`<a href="javascript:alert(1)">x</a>`, `x`},
+		{`<a href="http://example.com" onclick="alert(1)">x</a>`, `<a href="http://example.com" rel="nofollow noopener" target="_blank">x</a>`},
+		{`<input type="text" value="x">`, ``},
+		{`<input type="checkbox" checked="" disabled="" onclick="alert(1)">`, `<input type="checkbox" checked="" disabled="">`},
+		{`<p style="background:url(javascript:alert(1))">x</p>`, `<p>x</p>`},
+		{`<iframe src="http://example.com"></iframe>`, ``},
+	}
+
+	for _, test := range tests {
+		if got := policy.Sanitize(test.html); got != test.want {
+			t.Errorf("Sanitize(%q) = %q, want %q", test.html, got, test.want)
+		}
+	}
+}
+
+func TestRenderNeverEmitsScript(t *testing.T) {
+	for _, source := range []string{
+		"<scr<script>ipt>alert(1)</script>",
+		"```\n<script>alert(1)</script>\n```",
+		"[x](JaVaScRiPt:alert(1))",
+		"![x](javascript:alert(1))",
+		"<a href=\"javascript:alert(1)\">x</a>",
+	} {
+		got := strings.ToLower(Render(source))
+		for _, bad := range []string{"<script", "onerror", "javascript:"} {
+			if strings.Contains(got, bad) {
+				t.Errorf("Render(%q) = %q contains %s", source, got, bad)
+			}
+		}
+	}
+}
@@ -1,6 +1,9 @@
 package models
 
-import "time"
+import (
+	"encoding/json"
+	"time"
+)
 
 // User type
 type User struct {
@@ -13,14 +16,76 @@ type User struct {
 
 // Task type
 type Task struct {
-	ID          int64     `json:"id"`
-	Title       string    `json:"title"`
-	Description string    `json:"description"`
-	Tags        []string  `json:"tags"`
-	StatusID    int64     `json:"status_id"`
-	CreatedAt   time.Time `json:"created_at"`
-	UpdatedAt   time.Time `json:"updated_at"`
-	UserID      int64     `json:"user_id"`
+	ID           int64      `json:"id"`
+	Title        string     `json:"title"`
+	Description  string     `json:"description"`
+	Tags         []string   `json:"tags"`
+	StatusID     int64      `json:"status_id"`
+	CreatedAt    time.Time  `json:"created_at"`
+	UpdatedAt    time.Time  `json:"updated_at"`
+	UserID       int64      `json:"user_id"`
+	Version      int64      `json:"version"`
+	ProjectID    *int64     `json:"project_id"`
+	Priority     int64      `json:"priority"`
+	DueAt        *time.Time `json:"due_at"`
+	ParentID     *int64     `json:"parent_id"`
+	SnoozedUntil *time.Time `json:"snoozed_until"`
+
+	Checklist    *ChecklistProgress `json:"checklist,omitempty"`
+	CommentCount *int64             `json:"comment_count,omitempty"`
+
+	DescriptionHTML string      `json:"description_html,omitempty"`
+	Reactions       []*Reaction `json:"reactions,omitempty"`
+}
+
+// Task priorities, from lowest to highest
+const (
+	PriorityNone int64 = iota
+	PriorityLow
+	PriorityMedium
+	PriorityHigh
+	PriorityUrgent
+)
+
+// Project type
+type Project struct {
+	ID        int64     `json:"id"`
+	Name      string    `json:"name"`
+	CreatedAt time.Time `json:"created_at"`
+	UserID    int64     `json:"user_id"`
+}
+
+// BulkFilter selects tasks for a bulk operation when no ids are given
+type BulkFilter struct {
+	Status    string `json:"status"`
+	Tag       string `json:"tag"`
+	ProjectID *int64 `json:"project_id"`
+}
+
+// BulkRequest type
+type BulkRequest struct {
+	IDs       []int64     `json:"ids"`
+	Filter    *BulkFilter `json:"filter"`
+	Action    string      `json:"action"`
+	Tag       string      `json:"tag"`
+	ProjectID *int64      `json:"project_id"`
+	Priority  int64       `json:"priority"`
+}
+
+// BulkItemResult is the outcome of a bulk action on a single task
+type BulkItemResult struct {
+	ID    int64  `json:"id"`
+	OK    bool   `json:"ok"`
+	Error string `json:"error,omitempty"`
+	Task  *Task  `json:"task,omitempty"`
+}
+
+// BulkResult type
+type BulkResult struct {
+	Action    string            `json:"action"`
+	Succeeded int64             `json:"succeeded"`
+	Failed    int64             `json:"failed"`
+	Items     []*BulkItemResult `json:"items"`
 }
 
 // Comment type
@@ -30,17 +95,343 @@ type Comment struct {
 	CreatedAt time.Time `json:"created_at"`
 	TaskID    int64     `json:"task_id"`
 	UserID    int64     `json:"user_id"`
+	Version   int64     `json:"version"`
+
+	UpdatedAt       time.Time  `json:"updated_at"`
+	Edited          bool       `json:"edited"`
+	ParentCommentID *int64     `json:"parent_comment_id,omitempty"`
+	Deleted         bool       `json:"deleted,omitempty"`
+	ReplyCount      int64      `json:"reply_count"`
+	Replies         []*Comment `json:"replies,omitempty"`
+	Author          *Author    `json:"author,omitempty"`
+	Mentions        []*Author  `json:"mentions,omitempty"`
+
+	ContentHTML string      `json:"content_html,omitempty"`
+	Reactions   []*Reaction `json:"reactions,omitempty"`
+}
+
+// CommentEdit is a former content of a comment, replaced by an edit at EditedAt
+type CommentEdit struct {
+	ID        int64     `json:"id"`
+	CommentID int64     `json:"comment_id"`
+	Content   string    `json:"content"`
+	Version   int64     `json:"version"`
+	EditedAt  time.Time `json:"edited_at"`
+	UserID    int64     `json:"user_id"`
+}
+
+// Reaction is the count of one emoji on a task or comment
+type Reaction struct {
+	Emoji       string `json:"emoji"`
+	Count       int64  `json:"count"`
+	ReactedByMe bool   `json:"reacted_by_me"`
 }
 
-// TagStatus type
+// ReactionToggle is the body of a reaction toggle
+type ReactionToggle struct {
+	Emoji string `json:"emoji"`
+}
+
+// Author is the public part of a user shown next to what they wrote
+type Author struct {
+	ID       int64  `json:"id"`
+	Username string `json:"username"`
+}
+
+// TagStatus holds the task counts of one tag, keyed by status name
 type TagStatus struct {
-	Tag    string `json:"tag"`
-	Status string `json:"status"`
+	Tag          string           `json:"tag"`
+	Color        string           `json:"color"`
+	Statuses     map[string]int64 `json:"statuses"`
+	Total        int64            `json:"total"`
+	LastActivity *time.Time       `json:"last_activity"`
 }
 
+// TagStatusFilter narrows the tag statistics to a project and to tasks active within [From, To)
+type TagStatusFilter struct {
+	ProjectID *int64
+	From      *time.Time
+	To        *time.Time
+}
 
 type Status struct {
-	ID			int64	`json:"id"`
-	Name		string	`json:"name"`
-	CodeName	string	`json:"code_name"`
-}
\ No newline at end of file
+	ID       int64  `json:"id"`
+	Name     string `json:"name"`
+	CodeName string `json:"code_name"`
+}
+
+// TemplateItem is a subtask created together with a template's task
+type TemplateItem struct {
+	Title       string   `json:"title"`
+	Description string   `json:"description"`
+	Tags        []string `json:"tags"`
+	DueInDays   *int64   `json:"due_in_days"`
+}
+
+// Template type
+type Template struct {
+	ID          int64           `json:"id"`
+	Name        string          `json:"name"`
+	Title       string          `json:"title"`
+	Description string          `json:"description"`
+	Tags        []string        `json:"tags"`
+	DueInDays   *int64          `json:"due_in_days"`
+	Subtasks    []*TemplateItem `json:"subtasks"`
+	CreatedAt   time.Time       `json:"created_at"`
+	UserID      int64           `json:"user_id"`
+}
+
+// Instantiation holds the values a template is filled with
+type Instantiation struct {
+	Variables map[string]string `json:"variables"`
+	StartAt   *time.Time        `json:"start_at"`
+	ProjectID *int64            `json:"project_id"`
+}
+
+// TimeEntry is time spent on a task; a running timer has no EndedAt
+type TimeEntry struct {
+	ID        int64      `json:"id"`
+	TaskID    int64      `json:"task_id"`
+	UserID    int64      `json:"user_id"`
+	StartedAt time.Time  `json:"started_at"`
+	EndedAt   *time.Time `json:"ended_at"`
+	Note      string     `json:"note"`
+	Seconds   int64      `json:"seconds"`
+}
+
+// TaskTime holds the time entries of a task and their total
+type TaskTime struct {
+	TaskID  int64        `json:"task_id"`
+	Seconds int64        `json:"seconds"`
+	Entries []*TimeEntry `json:"entries"`
+}
+
+// TimeTotal is the tracked time of one task, tag or day
+type TimeTotal struct {
+	Key     string `json:"key"`
+	TaskID  *int64 `json:"task_id,omitempty"`
+	Seconds int64  `json:"seconds"`
+}
+
+// TimeReport type
+type TimeReport struct {
+	From    time.Time    `json:"from"`
+	To      time.Time    `json:"to"`
+	Seconds int64        `json:"seconds"`
+	ByTask  []*TimeTotal `json:"by_task"`
+	ByTag   []*TimeTotal `json:"by_tag"`
+	ByDay   []*TimeTotal `json:"by_day"`
+}
+
+// Attachment is a file uploaded to a task or one of its comments
+type Attachment struct {
+	ID          int64     `json:"id"`
+	TaskID      int64     `json:"task_id"`
+	CommentID   *int64    `json:"comment_id"`
+	UserID      int64     `json:"user_id"`
+	Filename    string    `json:"filename"`
+	ContentType string    `json:"content_type"`
+	Size        int64     `json:"size"`
+	SHA256      string    `json:"sha256"`
+	CreatedAt   time.Time `json:"created_at"`
+}
+
+// ChecklistItem is a small step inside a task
+type ChecklistItem struct {
+	ID        int64     `json:"id"`
+	TaskID    int64     `json:"task_id"`
+	Text      string    `json:"text"`
+	Checked   bool      `json:"checked"`
+	Position  int64     `json:"position"`
+	CreatedAt time.Time `json:"created_at"`
+}
+
+// ChecklistProgress summarizes the checklist of a task
+type ChecklistProgress struct {
+	Total   int64 `json:"total"`
+	Checked int64 `json:"checked"`
+}
+
+// ChecklistOrder lists all checklist item ids of a task in their new order
+type ChecklistOrder struct {
+	IDs []int64 `json:"ids"`
+}
+
+// BoardColumn holds one page of the tasks in a status
+type BoardColumn struct {
+	Status  *Status `json:"status"`
+	Count   int64   `json:"count"`
+	Page    int64   `json:"page"`
+	PerPage int64   `json:"per_page"`
+	Cards   []*Task `json:"cards"`
+}
+
+// Board type
+type Board struct {
+	Columns []*BoardColumn `json:"columns"`
+}
+
+// BoardQuery scopes the board and pages its columns
+type BoardQuery struct {
+	ProjectID *int64
+	Tag       string
+	PerPage   int64
+	// Pages maps status code names to the requested page, columns not listed start at page 1
+	Pages map[string]int64
+}
+
+// Event types
+const (
+	EventTaskWoken      = "task.woken"
+	EventCommentMention = "comment.mention"
+)
+
+// Event is a change recorded for a user, e.g. to notify them
+type Event struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Type      string          `json:"type"`
+	TaskID    *int64          `json:"task_id"`
+	Payload   json.RawMessage `json:"payload"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// Webhook is a subscription that posts the user's task and comment events to URL, optionally only for one project
+type Webhook struct {
+	ID        int64     `json:"id"`
+	URL       string    `json:"url"`
+	Secret    string    `json:"secret,omitempty"`
+	Events    []string  `json:"events"`
+	ProjectID *int64    `json:"project_id"`
+	Active    *bool     `json:"active"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    int64     `json:"user_id"`
+}
+
+// WebhookDelivery is one event queued for a webhook, with the log of its attempts
+type WebhookDelivery struct {
+	ID            int64             `json:"id"`
+	WebhookID     int64             `json:"webhook_id"`
+	Event         string            `json:"event"`
+	Payload       json.RawMessage   `json:"payload"`
+	Status        string            `json:"status"`
+	Attempts      int64             `json:"attempts"`
+	NextAttemptAt *time.Time        `json:"next_attempt_at"`
+	DeliveredAt   *time.Time        `json:"delivered_at"`
+	CreatedAt     time.Time         `json:"created_at"`
+	Log           []*WebhookAttempt `json:"log,omitempty"`
+}
+
+// WebhookAttempt is one try to deliver, StatusCode is nil when no response came back
+type WebhookAttempt struct {
+	ID           int64     `json:"id"`
+	StatusCode   *int      `json:"status_code"`
+	Error        string    `json:"error,omitempty"`
+	ResponseBody string    `json:"response_body,omitempty"`
+	DurationMS   int64     `json:"duration_ms"`
+	AttemptedAt  time.Time `json:"attempted_at"`
+}
+
+// Snooze type
+type Snooze struct {
+	Until time.Time `json:"until"`
+}
+
+// Tag type
+type Tag struct {
+	ID          int64     `json:"id"`
+	Name        string    `json:"name"`
+	Color       string    `json:"color"`
+	Description string    `json:"description"`
+	TaskCount   int64     `json:"task_count"`
+	CreatedAt   time.Time `json:"created_at"`
+	UserID      int64     `json:"user_id"`
+}
+
+// TagMerge moves every task of the source tags to the target tag
+type TagMerge struct {
+	SourceIDs []int64 `json:"source_ids"`
+	TargetID  int64   `json:"target_id"`
+}
+
+// SearchResult is a task matching a search, with its rank and highlighted snippets
+type SearchResult struct {
+	Task       *Task            `json:"task"`
+	Rank       float64          `json:"rank"`
+	Highlights *SearchHighlight `json:"highlights"`
+}
+
+// SearchHighlight holds HTML-escaped snippets with the matched words wrapped in <mark>
+type SearchHighlight struct {
+	Title       string `json:"title"`
+	Description string `json:"description"`
+	Comment     string `json:"comment"`
+}
+
+// Completion is a task or tag proposed while typing
+type Completion struct {
+	Type  string  `json:"type"`
+	ID    int64   `json:"id"`
+	Label string  `json:"label"`
+	Score float64 `json:"score"`
+}
+
+// PageRequest selects one page of a listing, either by page number or after/before a cursor
+type PageRequest struct {
+	Page    int64
+	PerPage int64
+	After   string
+	Before  string
+}
+
+// PageInfo describes the page a listing returned
+type PageInfo struct {
+	Total   int64
+	Page    int64
+	PerPage int64
+	Next    string
+	Prev    string
+}
+
+// SortField is one key of a listing's sort order
+type SortField struct {
+	Field string `json:"field"`
+	Desc  bool   `json:"desc"`
+}
+
+// View is a saved task listing; smart lists are built in, have a key instead of an id and cannot be changed
+type View struct {
+	ID        int64     `json:"id"`
+	Key       string    `json:"key,omitempty"`
+	Name      string    `json:"name"`
+	Filter    string    `json:"filter"`
+	Sort      string    `json:"sort"`
+	Columns   []string  `json:"columns"`
+	Smart     bool      `json:"smart"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+	UserID    int64     `json:"user_id"`
+}
+
+// Change is an entry of the change log streamed to clients, Data is the changed task or comment
+type Change struct {
+	ID        int64           `json:"id"`
+	UserID    int64           `json:"user_id"`
+	Type      string          `json:"type"`
+	TaskID    int64           `json:"task_id"`
+	ProjectID *int64          `json:"project_id"`
+	Data      json.RawMessage `json:"data"`
+	CreatedAt time.Time       `json:"created_at"`
+}
+
+// SocketMessage is a frame of the real-time channel in either direction, Topic is "task:<id>" or "project:<id>"
+type SocketMessage struct {
+	Type    string    `json:"type"`
+	Topic   string    `json:"topic,omitempty"`
+	Typing  *bool     `json:"typing,omitempty"`
+	User    *Author   `json:"user,omitempty"`
+	Viewers []*Author `json:"viewers,omitempty"`
+	Change  *Change   `json:"change,omitempty"`
+	Message string    `json:"message,omitempty"`
+}
@@ -16,17 +16,20 @@ type Meta struct {
 
 // Pagination is a struct for pagination
 type Pagination struct {
-	Total       int64 `json:"total"`
-	CurrentPage int64 `json:"current_page"`
-	LastPage    int64 `json:"last_page"`
-	From        int64 `json:"from"`
-	To          int64 `json:"to"`
+	Total       int64  `json:"total"`
+	CurrentPage int64  `json:"current_page"`
+	LastPage    int64  `json:"last_page"`
+	From        int64  `json:"from"`
+	To          int64  `json:"to"`
+	NextCursor  string `json:"next_cursor,omitempty"`
+	PrevCursor  string `json:"prev_cursor,omitempty"`
 }
 
 // Payload is a struct for Date Payload
 type Payload struct {
 	Items      interface{} `json:"items"`
 	Pagination *Pagination `json:"pagination,omitempty"`
+	DidYouMean string      `json:"did_you_mean,omitempty"`
 }
 
 // Response is a struct for response
@@ -60,6 +63,15 @@ func ResponseError(statusCode int, message string) *Response {
 	}
 }
 
+// ResponseErrorWithData is ResponseError carrying the current item, e.g. on a version conflict
+func ResponseErrorWithData(statusCode int, message string, data interface{}) *Response {
+	response := ResponseError(statusCode, message)
+	response.Payload = &Payload{
+		Items: data,
+	}
+	return response
+}
+
 // ToBytes convert to []byte message Response
 func (response *Response) ToBytes() []byte {
 	data, err := json.Marshal(response)
@@ -83,6 +95,30 @@ func (response *Response) Paginate(total, page, amount int64) (result *Response)
 	return
 }
 
+// PaginateCursor is Paginate for a page reached by cursor, where page numbers do not apply
+func (response *Response) PaginateCursor(total, amount int64) (result *Response) {
+	result = response
+	response.Payload.Pagination = &Pagination{
+		Total:    total,
+		LastPage: int64(math.Ceil(float64(total) / float64(amount))),
+	}
+
+	return
+}
+
+// Cursors sets the cursors of the pages around a paginated response
+func (response *Response) Cursors(next, prev string) *Response {
+	response.Payload.Pagination.NextCursor = next
+	response.Payload.Pagination.PrevCursor = prev
+	return response
+}
+
+// Suggest sets the "did you mean" query proposed for a search that found nothing
+func (response *Response) Suggest(query string) *Response {
+	response.Payload.DidYouMean = query
+	return response
+}
+
 func min(a, b int64) int64 {
 	if a > b {
 		return b
@@ -0,0 +1,229 @@
+package server
+
+import (
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"github.com/gorilla/mux"
+	"io"
+	"mime"
+	"net/http"
+	"path/filepath"
+	"strconv"
+)
+
+// defaultMaxAttachmentSize is used when attachments.max_size is not configured
+const defaultMaxAttachmentSize = 10 << 20
+
+// multipartMemory is how much of an upload is kept in memory before spilling to disk
+const multipartMemory = 1 << 20
+
+func (s *Server) maxAttachmentSize() int64 {
+	if size := s.config.GetInt("attachments.max_size"); size > 0 {
+		return size
+	}
+	return defaultMaxAttachmentSize
+}
+
+func (s *Server) allowedContentType(contentType string) bool {
+	allowed := s.config.GetStringSlice("attachments.allowed_types")
+	if len(allowed) == 0 {
+		return true
+	}
+	for _, item := range allowed {
+		if item == contentType {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) handleAddAttachment(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	maxSize := s.maxAttachmentSize()
+	request.Body = http.MaxBytesReader(writer, request.Body, maxSize+multipartMemory)
+	err = request.ParseMultipartForm(multipartMemory)
+	if err != nil {
+		lg.Error(err)
+		writer.Write(models.ResponseError(http.StatusRequestEntityTooLarge, "File Missing Or Too Large").ToBytes())
+		return
+	}
+	defer request.MultipartForm.RemoveAll()
+
+	file, header, err := request.FormFile("file")
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "File Missing").ToBytes())
+		return
+	}
+	defer file.Close()
+
+	// the declared content type is not trusted, the first bytes decide
+	sniff := make([]byte, 512)
+	n, err := io.ReadFull(file, sniff)
+	if err != nil && err != io.ErrUnexpectedEOF && err != io.EOF {
+		lg.Error(err)
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	contentType, _, _ := mime.ParseMediaType(http.DetectContentType(sniff[:n]))
+	if !s.allowedContentType(contentType) {
+		writer.Write(models.ResponseError(http.StatusUnsupportedMediaType, "File Type Not Allowed").ToBytes())
+		return
+	}
+	_, err = file.Seek(0, io.SeekStart)
+	if err != nil {
+		lg.Error(err)
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	attachment := &models.Attachment{
+		TaskID:      id,
+		Filename:    filepath.Base(header.Filename),
+		ContentType: contentType,
+	}
+	if commentParam := request.FormValue("comment_id"); commentParam != "" {
+		commentID, err := strconv.ParseInt(commentParam, 10, 64)
+		if err != nil {
+			writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+			return
+		}
+		attachment.CommentID = &commentID
+	}
+
+	items, err := s.userSvc.AddAttachment(request.Context(), attachment, file, maxSize, userID)
+	if errors.Is(err, service.ErrTooLarge) {
+		writer.Write(models.ResponseError(http.StatusRequestEntityTooLarge, "File Too Large").ToBytes())
+		return
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Task Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Attachment uploaded successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleGetTaskAttachments(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.GetTaskAttachments(request.Context(), id, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Task Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Attachments retrieved successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+// handleDownloadAttachment streams the file; http.ServeContent answers Range and conditional requests
+func (s *Server) handleDownloadAttachment(writer http.ResponseWriter, request *http.Request) {
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	attachment, content, err := s.userSvc.OpenAttachment(request.Context(), id, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(models.ResponseError(http.StatusNotFound, "Attachment Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Header().Set("Content-Type", "application/json")
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	defer content.Close()
+
+	writer.Header().Set("Content-Type", attachment.ContentType)
+	writer.Header().Set("Content-Disposition", mime.FormatMediaType("attachment", map[string]string{"filename": attachment.Filename}))
+	writer.Header().Set("X-Content-Type-Options", "nosniff")
+	writer.Header().Set("ETag", `"`+attachment.SHA256+`"`)
+	http.ServeContent(writer, request, attachment.Filename, attachment.CreatedAt, content)
+}
+
+func (s *Server) handleDeleteAttachmentByID(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.DeleteAttachmentByID(request.Context(), id, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Attachment Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Attachment Successfully Deleted!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
@@ -0,0 +1,71 @@
+package server
+
+import (
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// Board column page sizes
+const (
+	defaultBoardPerPage = 20
+	maxBoardPerPage     = 100
+)
+
+func (s *Server) handleGetBoard(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	params := request.URL.Query()
+	query := &models.BoardQuery{
+		Tag:     params.Get("tag"),
+		PerPage: defaultBoardPerPage,
+		Pages:   make(map[string]int64),
+	}
+
+	if projectParam := params.Get("project_id"); projectParam != "" {
+		projectID, err := strconv.ParseInt(projectParam, 10, 64)
+		if err != nil {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Project").ToBytes())
+			return
+		}
+		query.ProjectID = &projectID
+	}
+	if perPageParam := params.Get("per_page"); perPageParam != "" {
+		perPage, err := strconv.ParseInt(perPageParam, 10, 64)
+		if err != nil || perPage < 1 || perPage > maxBoardPerPage {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Page Size").ToBytes())
+			return
+		}
+		query.PerPage = perPage
+	}
+	// page.<code_name>=N pages a single column, e.g. page.completed=2
+	for key, values := range params {
+		if !strings.HasPrefix(key, "page.") || len(values) == 0 {
+			continue
+		}
+		page, err := strconv.ParseInt(values[0], 10, 64)
+		if err != nil || page < 1 {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Page").ToBytes())
+			return
+		}
+		query.Pages[strings.TrimPrefix(key, "page.")] = page
+	}
+
+	items, err := s.userSvc.GetBoard(request.Context(), query, userID)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Board retrieved successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
@@ -0,0 +1,46 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"net/http"
+)
+
+func (s *Server) handleBulkTasks(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	var bulk *models.BulkRequest
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+	err := json.NewDecoder(request.Body).Decode(&bulk)
+
+	if err != nil {
+		lg.Error(err)
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+
+	items, err := s.userSvc.BulkTasks(request.Context(), bulk, userID)
+
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Project Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	_, err = writer.Write(models.ResponseWrite("Bulk operation finished!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
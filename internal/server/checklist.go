@@ -0,0 +1,211 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (s *Server) handleGetChecklist(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.GetChecklist(request.Context(), id, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Task Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Checklist retrieved successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleAddChecklistItem(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	var item *models.ChecklistItem
+	err = json.NewDecoder(request.Body).Decode(&item)
+	if err != nil || len(strings.TrimSpace(item.Text)) == 0 {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	item.TaskID = id
+
+	items, err := s.userSvc.AddChecklistItem(request.Context(), item, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Task Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Checklist item added successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleUpdateChecklistItem(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(request)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	itemID, err := strconv.ParseInt(vars["itemID"], 10, 64)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	var item *models.ChecklistItem
+	err = json.NewDecoder(request.Body).Decode(&item)
+	if err != nil || len(strings.TrimSpace(item.Text)) == 0 {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	item.ID = itemID
+	item.TaskID = id
+
+	items, err := s.userSvc.UpdateChecklistItem(request.Context(), item, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Checklist Item Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Checklist item successfully updated!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleDeleteChecklistItem(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	vars := mux.Vars(request)
+	id, err := strconv.ParseInt(vars["id"], 10, 64)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	itemID, err := strconv.ParseInt(vars["itemID"], 10, 64)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.DeleteChecklistItem(request.Context(), id, itemID, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Checklist Item Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Checklist Item Successfully Deleted!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleReorderChecklist(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	var order *models.ChecklistOrder
+	err = json.NewDecoder(request.Body).Decode(&order)
+	if err != nil {
+		lg.Error(err)
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+
+	items, err := s.userSvc.ReorderChecklist(request.Context(), id, order, userID)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Order Must List Every Checklist Item Once").ToBytes())
+		return
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Task Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Checklist reordered successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
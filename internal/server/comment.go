@@ -0,0 +1,195 @@
+package server
+
+import (
+	"errors"
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"github.com/gorilla/mux"
+	"net/http"
+	"net/url"
+	"strconv"
+)
+
+// commentDepth reads how many levels of replies to nest, none by default
+func commentDepth(query url.Values) (int, error) {
+	value := query.Get("depth")
+	if value == "" {
+		return 0, nil
+	}
+	depth, err := strconv.Atoi(value)
+	if err != nil || depth < 0 || depth > service.MaxCommentDepth {
+		return 0, fmt.Errorf("depth must be between 0 and %d", service.MaxCommentDepth)
+	}
+	return depth, nil
+}
+
+func (s *Server) handleGetTaskComments(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	depth, err := commentDepth(request.URL.Query())
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+	page, err := pageRequest(request.URL.Query())
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+
+	items, info, err := s.userSvc.GetTaskComments(request.Context(), id, userID, depth, page)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Task Not Found").ToBytes())
+		return
+	}
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Cursor").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	if wantsHTML(request.URL.Query()) {
+		renderComments(items...)
+	}
+	_, err = writer.Write(paginate(models.ResponseWrite("Comments successfully retrieved!", items), info).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleGetCommentReplies(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	depth, err := commentDepth(request.URL.Query())
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+	page, err := pageRequest(request.URL.Query())
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+
+	items, info, err := s.userSvc.GetCommentReplies(request.Context(), id, userID, depth, page)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Comment Not Found").ToBytes())
+		return
+	}
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Cursor").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	if wantsHTML(request.URL.Query()) {
+		renderComments(items...)
+	}
+	_, err = writer.Write(paginate(models.ResponseWrite("Replies successfully retrieved!", items), info).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleGetCommentByID(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.GetCommentByID(request.Context(), id, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Comment Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	if wantsHTML(request.URL.Query()) {
+		renderComments(items)
+	}
+
+	writer.Header().Set("ETag", versionETag("comment", items.ID, items.Version))
+	_, err = writer.Write(models.ResponseWrite("Comment Successfully Retrieved!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleGetCommentHistory(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.GetCommentHistory(request.Context(), id, userID)
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, "Comment Not Found").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	_, err = writer.Write(models.ResponseWrite("Comment history retrieved successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
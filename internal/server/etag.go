@@ -0,0 +1,73 @@
+package server
+
+import (
+	"encoding/json"
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"hash/crc32"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// versionETag builds the strong entity tag of a versioned item, e.g. "task-12-3"
+func versionETag(kind string, id int64, version int64) string {
+	return fmt.Sprintf(`"%s-%d-%d"`, kind, id, version)
+}
+
+// representationETag is versionETag followed by a digest of what the response carries besides the versioned
+// fields, e.g. "task-12-3.5d41402a". Reactions and checklist progress change without a new version, and so does
+// the body with ?html=true, so a conditional GET must not answer 304 for them. If-Match only compares the version
+func representationETag(kind string, id int64, version int64, parts ...interface{}) string {
+	data, err := json.Marshal(parts)
+	if err != nil {
+		return versionETag(kind, id, version)
+	}
+	return fmt.Sprintf(`"%s-%d-%d.%08x"`, kind, id, version, crc32.ChecksumIEEE(data))
+}
+
+// ifMatchVersion returns the version the client expects from the If-Match header.
+// 0 means no precondition, -1 means a precondition that can never match.
+func ifMatchVersion(request *http.Request, kind string, id int64) int64 {
+	header := strings.TrimSpace(request.Header.Get("If-Match"))
+	if header == "" || header == "*" {
+		return 0
+	}
+
+	prefix := fmt.Sprintf("%s-%d-", kind, id)
+	for _, tag := range strings.Split(header, ",") {
+		tag = strings.Trim(strings.TrimSpace(tag), `"`)
+		if !strings.HasPrefix(tag, prefix) {
+			continue
+		}
+		tag = strings.TrimPrefix(tag, prefix)
+		if dot := strings.IndexByte(tag, '.'); dot >= 0 {
+			tag = tag[:dot]
+		}
+		version, err := strconv.ParseInt(tag, 10, 64)
+		if err == nil && version > 0 {
+			return version
+		}
+	}
+
+	return -1
+}
+
+// preconditionFailed answers a failed If-Match with the current item and its tag. Unlike other errors it also sets
+// the HTTP status: 412 is defined by HTTP for conditional requests, and clients check it before reading the body
+func preconditionFailed(writer http.ResponseWriter, etag string, message string, item interface{}) {
+	writer.Header().Set("ETag", etag)
+	writer.WriteHeader(http.StatusPreconditionFailed)
+	writer.Write(models.ResponseErrorWithData(http.StatusPreconditionFailed, message, item).ToBytes())
+}
+
+// notModified reports whether the client already holds the given entity tag
+func notModified(request *http.Request, etag string) bool {
+	for _, tag := range strings.Split(request.Header.Get("If-None-Match"), ",") {
+		tag = strings.TrimPrefix(strings.TrimSpace(tag), "W/")
+		if tag == etag || tag == "*" {
+			return true
+		}
+	}
+	return false
+}
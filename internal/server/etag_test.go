@@ -0,0 +1,100 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestRepresentationETag(t *testing.T) {
+	progress := &models.ChecklistProgress{Total: 3, Checked: 1}
+	reactions := []*models.Reaction{{Emoji: "👍", Count: 1, ReactedByMe: true}}
+	base := representationETag("task", 12, 3, progress, reactions, false)
+
+	if base != representationETag("task", 12, 3, &models.ChecklistProgress{Total: 3, Checked: 1}, reactions, false) {
+		t.Error("the same representation gives different tags")
+	}
+	for name, etag := range map[string]string{
+		"version":   representationETag("task", 12, 4, progress, reactions, false),
+		"checklist": representationETag("task", 12, 3, &models.ChecklistProgress{Total: 3, Checked: 2}, reactions, false),
+		"reactions": representationETag("task", 12, 3, progress, []*models.Reaction{{Emoji: "👍", Count: 2, ReactedByMe: true}}, false),
+		"html":      representationETag("task", 12, 3, progress, reactions, true),
+	} {
+		if etag == base {
+			t.Errorf("a changed %s keeps the tag %s", name, base)
+		}
+	}
+}
+
+func TestIfMatchVersion(t *testing.T) {
+	tests := []struct {
+		header string
+		want   int64
+	}{
+		{``, 0},
+		{`*`, 0},
+		{`"task-12-3"`, 3},
+		{representationETag("task", 12, 3, nil, false), 3},
+		{`W/"task-12-5.0badf00d"`, -1},
+		{`"task-13-3"`, -1},
+		{`"comment-12-3"`, -1},
+		{`"task-12-x"`, -1},
+		{`"task-13-1", "task-12-7.00000000"`, 7},
+	}
+
+	for _, test := range tests {
+		request := httptest.NewRequest("PUT", "/api/tasks", nil)
+		request.Header.Set("If-Match", test.header)
+		if got := ifMatchVersion(request, "task", 12); got != test.want {
+			t.Errorf("ifMatchVersion(%s) = %d, want %d", test.header, got, test.want)
+		}
+	}
+}
+
+func TestNotModified(t *testing.T) {
+	etag := representationETag("task", 12, 3, nil, true)
+	for header, want := range map[string]bool{
+		"":                 false,
+		etag:               true,
+		"W/" + etag:        true,
+		`"task-12-3"`:      false,
+		`"other", ` + etag: true,
+		"*":                true,
+	} {
+		request := httptest.NewRequest("GET", "/api/tasks/12", nil)
+		request.Header.Set("If-None-Match", header)
+		if got := notModified(request, etag); got != want {
+			t.Errorf("notModified(%s) = %v, want %v", header, got, want)
+		}
+	}
+}
+
+func TestPreconditionFailed(t *testing.T) {
+	recorder := httptest.NewRecorder()
+	recorder.Header().Set("Content-Type", "application/json")
+	preconditionFailed(recorder, versionETag("task", 12, 4), "Task Was Modified", &models.Task{ID: 12, Version: 4})
+
+	if recorder.Code != http.StatusPreconditionFailed {
+		t.Errorf("status = %d, want 412", recorder.Code)
+	}
+	if etag := recorder.Header().Get("ETag"); etag != `"task-12-4"` {
+		t.Errorf("ETag = %s", etag)
+	}
+	var body struct {
+		Meta struct {
+			Code int `json:"code"`
+		} `json:"meta"`
+		Payload struct {
+			Items *models.Task `json:"items"`
+		} `json:"payload"`
+	}
+	err := json.Unmarshal(recorder.Body.Bytes(), &body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if body.Meta.Code != http.StatusPreconditionFailed || body.Payload.Items == nil || body.Payload.Items.Version != 4 {
+		t.Errorf("body = %s", recorder.Body)
+	}
+}
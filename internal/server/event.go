@@ -0,0 +1,27 @@
+package server
+
+import (
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"net/http"
+)
+
+func (s *Server) handleGetNotifications(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.GetNotifications(request.Context(), userID)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	_, err = writer.Write(models.ResponseWrite("Notifications retrieved successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
@@ -0,0 +1,42 @@
+package server
+
+import (
+	"github.com/AlifAcademy/TodoList/internal/filter"
+	"net/url"
+	"time"
+)
+
+// taskFilter builds the filter of a task listing from the filter param, ANDed with the older status, tag and search params
+func taskFilter(query url.Values) (filter.Node, error) {
+	now := time.Now()
+	expr, err := filter.Parse(query.Get("filter"), now)
+	if err != nil {
+		return nil, err
+	}
+
+	either := &filter.Or{}
+	if status := query.Get("status"); status != "" {
+		term, err := filter.NewTerm(filter.FieldStatus, filter.OpEq, []string{status}, now)
+		if err != nil {
+			return nil, err
+		}
+		either.Nodes = append(either.Nodes, term)
+	}
+	if tag := query.Get("tag"); tag != "" {
+		term, err := filter.NewTerm(filter.FieldTag, filter.OpEq, []string{tag}, now)
+		if err != nil {
+			return nil, err
+		}
+		either.Nodes = append(either.Nodes, term)
+	}
+
+	var legacy filter.Node
+	if len(either.Nodes) > 0 {
+		legacy = either
+	}
+	var search filter.Node
+	if text := query.Get("search"); text != "" {
+		search = &filter.Text{Value: text}
+	}
+	return filter.Join(expr, legacy, search), nil
+}
@@ -0,0 +1,27 @@
+package server
+
+import (
+	"github.com/AlifAcademy/TodoList/internal/markdown"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"net/url"
+	"strconv"
+)
+
+// wantsHTML reports whether the client asked for rendered markdown next to the raw source with ?html=true
+func wantsHTML(query url.Values) bool {
+	render, _ := strconv.ParseBool(query.Get("html"))
+	return render
+}
+
+func renderTasks(tasks ...*models.Task) {
+	for _, task := range tasks {
+		task.DescriptionHTML = markdown.Render(task.Description)
+	}
+}
+
+func renderComments(comments ...*models.Comment) {
+	for _, comment := range comments {
+		comment.ContentHTML = markdown.Render(comment.Content)
+		renderComments(comment.Replies...)
+	}
+}
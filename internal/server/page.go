@@ -0,0 +1,46 @@
+package server
+
+import (
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"net/url"
+	"strconv"
+)
+
+// pageRequest reads page and per_page, or an after/before cursor, from the query
+func pageRequest(query url.Values) (*models.PageRequest, error) {
+	page := &models.PageRequest{After: query.Get("after"), Before: query.Get("before")}
+	if page.After != "" && page.Before != "" {
+		return nil, errors.New("use either after or before")
+	}
+
+	if pageParam := query.Get("page"); pageParam != "" {
+		if page.After != "" || page.Before != "" {
+			return nil, errors.New("use either page or a cursor")
+		}
+		value, err := strconv.ParseInt(pageParam, 10, 64)
+		if err != nil || value <= 0 {
+			return nil, errors.New("invalid page")
+		}
+		page.Page = value
+	}
+
+	if perPageParam := query.Get("per_page"); perPageParam != "" {
+		value, err := strconv.ParseInt(perPageParam, 10, 64)
+		if err != nil || value <= 0 {
+			return nil, errors.New("invalid per_page")
+		}
+		page.PerPage = value
+	}
+	return page, nil
+}
+
+// paginate fills the response's pagination from the page a listing returned
+func paginate(response *models.Response, info *models.PageInfo) *models.Response {
+	if info.Page > 0 {
+		response.Paginate(info.Total, info.Page, info.PerPage)
+	} else {
+		response.PaginateCursor(info.Total, info.PerPage)
+	}
+	return response.Cursors(info.Next, info.Prev)
+}
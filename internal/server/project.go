@@ -0,0 +1,55 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"net/http"
+	"strings"
+)
+
+func (s *Server) handleNewProject(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	var project *models.Project
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+	err := json.NewDecoder(request.Body).Decode(&project)
+
+	if err != nil || len(strings.TrimSpace(project.Name)) == 0 {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+
+	items, err := s.userSvc.NewProject(request.Context(), project, userID)
+
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	_, err = writer.Write(models.ResponseWrite("New Project Successfully Created!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+func (s *Server) handleGetAllProjects(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	items, err := s.userSvc.GetAllProjects(request.Context(), userID)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	_, err = writer.Write(models.ResponseWrite("Projects successfully retrieved!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
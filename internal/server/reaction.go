@@ -0,0 +1,72 @@
+package server
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"github.com/gorilla/mux"
+	"net/http"
+	"strconv"
+)
+
+// reactionToggler is ToggleTaskReaction or ToggleCommentReaction
+type reactionToggler func(ctx context.Context, id int64, userID int64, emoji string) ([]*models.Reaction, bool, error)
+
+func (s *Server) handleToggleTaskReaction(writer http.ResponseWriter, request *http.Request) {
+	s.toggleReaction(writer, request, s.userSvc.ToggleTaskReaction, "Task Not Found")
+}
+
+func (s *Server) handleToggleCommentReaction(writer http.ResponseWriter, request *http.Request) {
+	s.toggleReaction(writer, request, s.userSvc.ToggleCommentReaction, "Comment Not Found")
+}
+
+func (s *Server) toggleReaction(writer http.ResponseWriter, request *http.Request, toggle reactionToggler, notFound string) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	idParam, ok := mux.Vars(request)["id"]
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+	id, err := strconv.ParseInt(idParam, 10, 64)
+	if err != nil {
+		lg.Error(err)
+	}
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	var reaction *models.ReactionToggle
+	err = json.NewDecoder(request.Body).Decode(&reaction)
+	if err != nil || reaction == nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
+		return
+	}
+
+	items, reacted, err := toggle(request.Context(), id, userID, reaction.Emoji)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+	if errors.Is(err, service.ErrNotFound) {
+		writer.Write(models.ResponseError(http.StatusNotFound, notFound).ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	message := "Reaction removed!"
+	if reacted {
+		message = "Reaction added!"
+	}
+	_, err = writer.Write(models.ResponseWrite(message, items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
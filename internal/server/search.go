@@ -0,0 +1,113 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+func (s *Server) handleSearch(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	query := request.URL.Query()
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	text := strings.TrimSpace(query.Get("q"))
+	if text == "" {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Search Query Required").ToBytes())
+		return
+	}
+
+	var limit int64 = 20
+	if limitParam := query.Get("limit"); limitParam != "" {
+		parsed, err := strconv.ParseInt(limitParam, 10, 64)
+		if err != nil || parsed <= 0 {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Limit").ToBytes())
+			return
+		}
+		limit = parsed
+	}
+
+	expr, err := taskFilter(query)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+
+	items, err := s.userSvc.Search(request.Context(), userID, text, expr, limit)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Search Query Has No Words").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	if wantsHTML(query) {
+		for _, item := range items {
+			renderTasks(item.Task)
+		}
+	}
+	response := models.ResponseWrite("Search results retrieved successfully!", items)
+	if len(items) == 0 {
+		response.Suggest(s.didYouMean(request.Context(), userID, text))
+	}
+	_, err = writer.Write(response.ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
+
+// didYouMean asks for a corrected query when a search found nothing; a failed lookup only drops the suggestion
+func (s *Server) didYouMean(ctx context.Context, userID int64, texts ...string) string {
+	if len(texts) == 0 {
+		return ""
+	}
+	suggestion, err := s.userSvc.SuggestQuery(ctx, userID, strings.Join(texts, " "))
+	if err != nil {
+		return ""
+	}
+	return suggestion
+}
+
+func (s *Server) handleAutocomplete(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	query := request.URL.Query()
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	var limit int64 = 10
+	if limitParam := query.Get("limit"); limitParam != "" {
+		parsed, err := strconv.ParseInt(limitParam, 10, 64)
+		if err != nil || parsed <= 0 {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Limit").ToBytes())
+			return
+		}
+		limit = parsed
+	}
+
+	items, err := s.userSvc.Autocomplete(request.Context(), userID, query.Get("q"), limit)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Search Query Required").ToBytes())
+		return
+	}
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+	_, err = writer.Write(models.ResponseWrite("Completions retrieved successfully!", items).ToBytes())
+
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+}
@@ -1,8 +1,11 @@
 package server
 
 import (
+	"context"
 	"encoding/json"
 	"errors"
+	"github.com/AlifAcademy/TodoList/config"
+	"github.com/AlifAcademy/TodoList/internal/filter"
 	"github.com/AlifAcademy/TodoList/internal/logger"
 	"github.com/AlifAcademy/TodoList/internal/middleware"
 	"github.com/AlifAcademy/TodoList/internal/models"
@@ -10,7 +13,6 @@ import (
 	"github.com/AlifAcademy/TodoList/internal/service/security"
 	"github.com/AlifAcademy/TodoList/pkg/types"
 	"github.com/gorilla/mux"
-	"log"
 	"net/http"
 	"strconv"
 	"time"
@@ -23,7 +25,11 @@ type Server struct {
 	mux         *mux.Router
 	userSvc     *service.Service
 	securitySvc *security.Service
-	//config *config.Config
+	config      config.Config
+	hub         *hub
+
+	// canWatch decides who may subscribe to a socket topic, userSvc.CanWatch unless a test replaces it
+	canWatch func(ctx context.Context, userID int64, kind string, id int64) error
 }
 
 const (
@@ -41,8 +47,8 @@ const (
 )
 
 // NewServer constructor
-func NewServer(mux *mux.Router, userSvc *service.Service, securitySvc *security.Service) *Server {
-	return &Server{mux: mux, userSvc: userSvc, securitySvc: securitySvc}
+func NewServer(mux *mux.Router, userSvc *service.Service, securitySvc *security.Service, config config.Config) *Server {
+	return &Server{mux: mux, userSvc: userSvc, securitySvc: securitySvc, config: config, hub: newHub(), canWatch: userSvc.CanWatch}
 }
 
 func (s *Server) ServeHTTP(w http.ResponseWriter, r *http.Request) {
@@ -63,10 +69,75 @@ func (s *Server) Init() {
 	s.mux.Handle("/api/tasks", chMd(http.HandlerFunc(s.handleUpdateTask))).Methods(UPDATE)
 	s.mux.Handle("/api/tasks/complete/{id}", chMd(http.HandlerFunc(s.handleMarkTaskAsCompeted))).Methods(UPDATE)
 	s.mux.Handle("/api/tasks/cancel/{id}", chMd(http.HandlerFunc(s.handleMarkTaskAsCanceled))).Methods(UPDATE)
+	s.mux.Handle("/api/tasks/bulk", chMd(http.HandlerFunc(s.handleBulkTasks))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/snooze", chMd(http.HandlerFunc(s.handleSnoozeTask))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/snooze", chMd(http.HandlerFunc(s.handleUnsnoozeTask))).Methods(DELETE)
+	s.mux.Handle("/api/notifications", chMd(http.HandlerFunc(s.handleGetNotifications))).Methods(GET)
+	s.mux.Handle("/api/events", chMd(http.HandlerFunc(s.handleEvents))).Methods(GET)
+	s.mux.Handle("/api/ws", chMd(http.HandlerFunc(s.handleSocket))).Methods(GET)
+
+	s.mux.Handle("/api/projects", chMd(http.HandlerFunc(s.handleNewProject))).Methods(POST)
+	s.mux.Handle("/api/projects", chMd(http.HandlerFunc(s.handleGetAllProjects))).Methods(GET)
+
+	s.mux.Handle("/api/templates", chMd(http.HandlerFunc(s.handleNewTemplate))).Methods(POST)
+	s.mux.Handle("/api/templates", chMd(http.HandlerFunc(s.handleGetAllTemplates))).Methods(GET)
+	s.mux.Handle("/api/templates", chMd(http.HandlerFunc(s.handleUpdateTemplate))).Methods(UPDATE)
+	s.mux.Handle("/api/templates/{id}", chMd(http.HandlerFunc(s.handleGetTemplateByID))).Methods(GET)
+	s.mux.Handle("/api/templates/{id}", chMd(http.HandlerFunc(s.handleDeleteTemplateByID))).Methods(DELETE)
+	s.mux.Handle("/api/templates/{id}/instantiate", chMd(http.HandlerFunc(s.handleInstantiateTemplate))).Methods(POST)
+
+	s.mux.Handle("/api/tasks/{id}/timer/start", chMd(http.HandlerFunc(s.handleStartTimer))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/timer/stop", chMd(http.HandlerFunc(s.handleStopTimer))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/time", chMd(http.HandlerFunc(s.handleAddTimeEntry))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/time", chMd(http.HandlerFunc(s.handleGetTaskTime))).Methods(GET)
+	s.mux.Handle("/api/time/{id}", chMd(http.HandlerFunc(s.handleDeleteTimeEntryByID))).Methods(DELETE)
+	s.mux.Handle("/api/time", chMd(http.HandlerFunc(s.handleGetTimeReport))).Methods(GET)
+
+	s.mux.Handle("/api/tasks/{id}/attachments", chMd(http.HandlerFunc(s.handleAddAttachment))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/attachments", chMd(http.HandlerFunc(s.handleGetTaskAttachments))).Methods(GET)
+	s.mux.Handle("/api/attachments/{id}", chMd(http.HandlerFunc(s.handleDownloadAttachment))).Methods(GET)
+	s.mux.Handle("/api/attachments/{id}", chMd(http.HandlerFunc(s.handleDeleteAttachmentByID))).Methods(DELETE)
+
+	s.mux.Handle("/api/tasks/{id}/checklist", chMd(http.HandlerFunc(s.handleGetChecklist))).Methods(GET)
+	s.mux.Handle("/api/tasks/{id}/checklist", chMd(http.HandlerFunc(s.handleAddChecklistItem))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/checklist/reorder", chMd(http.HandlerFunc(s.handleReorderChecklist))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/checklist/{itemID}", chMd(http.HandlerFunc(s.handleUpdateChecklistItem))).Methods(UPDATE)
+	s.mux.Handle("/api/tasks/{id}/checklist/{itemID}", chMd(http.HandlerFunc(s.handleDeleteChecklistItem))).Methods(DELETE)
+	s.mux.Handle("/api/comments/{id}", chMd(http.HandlerFunc(s.handleGetCommentByID))).Methods(GET)
 	s.mux.Handle("/api/comments/{id}", chMd(http.HandlerFunc(s.handleDeleteCommentByID))).Methods(DELETE)
 
 	s.mux.Handle("/api/comments", chMd(http.HandlerFunc(s.handleAddComment))).Methods(POST)
-
+	s.mux.Handle("/api/tasks/{id}/comments", chMd(http.HandlerFunc(s.handleGetTaskComments))).Methods(GET)
+	s.mux.Handle("/api/comments/{id}/replies", chMd(http.HandlerFunc(s.handleGetCommentReplies))).Methods(GET)
+	s.mux.Handle("/api/comments/{id}/history", chMd(http.HandlerFunc(s.handleGetCommentHistory))).Methods(GET)
+	s.mux.Handle("/api/comments/{id}/reactions", chMd(http.HandlerFunc(s.handleToggleCommentReaction))).Methods(POST)
+	s.mux.Handle("/api/tasks/{id}/reactions", chMd(http.HandlerFunc(s.handleToggleTaskReaction))).Methods(POST)
+
+	s.mux.Handle("/api/tags", chMd(http.HandlerFunc(s.handleNewTag))).Methods(POST)
+	s.mux.Handle("/api/tags", chMd(http.HandlerFunc(s.handleGetAllTags))).Methods(GET)
+	s.mux.Handle("/api/tags", chMd(http.HandlerFunc(s.handleUpdateTag))).Methods(UPDATE)
+	s.mux.Handle("/api/tags/merge", chMd(http.HandlerFunc(s.handleMergeTags))).Methods(POST)
+	s.mux.Handle("/api/tags/{id}", chMd(http.HandlerFunc(s.handleDeleteTagByID))).Methods(DELETE)
+
+	s.mux.Handle("/api/views", chMd(http.HandlerFunc(s.handleNewView))).Methods(POST)
+	s.mux.Handle("/api/views", chMd(http.HandlerFunc(s.handleGetAllViews))).Methods(GET)
+	s.mux.Handle("/api/views", chMd(http.HandlerFunc(s.handleUpdateView))).Methods(UPDATE)
+	s.mux.Handle("/api/views/smart", chMd(http.HandlerFunc(s.handleGetSmartLists))).Methods(GET)
+	s.mux.Handle("/api/views/{id:[0-9]+}", chMd(http.HandlerFunc(s.handleGetViewByID))).Methods(GET)
+	s.mux.Handle("/api/views/{id:[0-9]+}", chMd(http.HandlerFunc(s.handleDeleteViewByID))).Methods(DELETE)
+	s.mux.Handle("/api/views/{id}/tasks", chMd(http.HandlerFunc(s.handleGetViewTasks))).Methods(GET)
+
+	s.mux.Handle("/api/webhooks", chMd(http.HandlerFunc(s.handleNewWebhook))).Methods(POST)
+	s.mux.Handle("/api/webhooks", chMd(http.HandlerFunc(s.handleGetAllWebhooks))).Methods(GET)
+	s.mux.Handle("/api/webhooks", chMd(http.HandlerFunc(s.handleUpdateWebhook))).Methods(UPDATE)
+	s.mux.Handle("/api/webhooks/{id}", chMd(http.HandlerFunc(s.handleDeleteWebhookByID))).Methods(DELETE)
+	s.mux.Handle("/api/webhooks/{id}/deliveries", chMd(http.HandlerFunc(s.handleGetWebhookDeliveries))).Methods(GET)
+	s.mux.Handle("/api/webhooks/deliveries/{id}/redeliver", chMd(http.HandlerFunc(s.handleRedeliverWebhook))).Methods(POST)
+
+	s.mux.Handle("/api/search", chMd(http.HandlerFunc(s.handleSearch))).Methods(GET)
+	s.mux.Handle("/api/autocomplete", chMd(http.HandlerFunc(s.handleAutocomplete))).Methods(GET)
+
+	s.mux.Handle("/api/board", chMd(http.HandlerFunc(s.handleGetBoard))).Methods(GET)
 	s.mux.Handle("/api/tagstatus", chMd(http.HandlerFunc(s.handleGetStatusAndTag))).Methods(GET)
 	s.mux.Handle("/api/comments", chMd(http.HandlerFunc(s.handleUpdateComment))).Methods(UPDATE)
 
@@ -106,7 +177,7 @@ func (s *Server) handleNewTask(writer http.ResponseWriter, request *http.Request
 	userID := value.(int64)
 	err := json.NewDecoder(request.Body).Decode(&task)
 
-	if err != nil || len(task.Title) == 0 {
+	if err != nil || len(task.Title) == 0 || task.Priority < models.PriorityNone || task.Priority > models.PriorityUrgent {
 		writer.Write(models.ResponseError(http.StatusBadRequest, http.StatusText(http.StatusBadRequest)).ToBytes())
 		return
 	}
@@ -120,6 +191,7 @@ func (s *Server) handleNewTask(writer http.ResponseWriter, request *http.Request
 		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
 		return
 	}
+	writer.Header().Set("ETag", versionETag("task", items.ID, items.Version))
 	_, err = writer.Write(models.ResponseWrite("New Task Successfully Created!", items).ToBytes())
 
 	if err != nil {
@@ -143,7 +215,11 @@ func (s *Server) handleDeleteTaskByID(writer http.ResponseWriter, request *http.
 	value := request.Context().Value(types.Key("key"))
 	userID := value.(int64)
 
-	items, err := s.userSvc.DeleteTaskByID(request.Context(), id, userID)
+	items, err := s.userSvc.DeleteTaskByID(request.Context(), id, userID, ifMatchVersion(request, "task", id))
+	if errors.Is(err, service.ErrPreconditionFailed) {
+		preconditionFailed(writer, versionETag("task", items.ID, items.Version), "Task Was Modified", items)
+		return
+	}
 	if errors.Is(err, service.ErrNotFound) {
 		writer.Write(models.ResponseError(http.StatusNotFound, "Task Not Found").ToBytes())
 		return
@@ -162,26 +238,55 @@ func (s *Server) handleDeleteTaskByID(writer http.ResponseWriter, request *http.
 }
 
 func (s *Server) handleGetAllTasks(writer http.ResponseWriter, request *http.Request) {
-	status := request.URL.Query().Get("status")
-	tag := request.URL.Query().Get("tag")
-	searchText := request.URL.Query().Get("search")
-	log.Print(tag)
+	includeSnoozed, _ := strconv.ParseBool(request.URL.Query().Get("snoozed"))
+	commentCount, _ := strconv.ParseBool(request.URL.Query().Get("comment_count"))
 
 	writer.Header().Set("Content-Type", "application/json")
 
 	value := request.Context().Value(types.Key("key"))
 	userID := value.(int64)
 
-	items, err := s.userSvc.GetAllTasks(request.Context(), userID, tag, status, searchText)
-	if errors.Is(err, service.ErrNotFound) {
-		writer.Write(models.ResponseError(http.StatusNotFound, http.StatusText(http.StatusNotFound)).ToBytes())
+	expr, err := taskFilter(request.URL.Query())
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+	sorting, err := service.ParseTaskSort(request.URL.Query().Get("sort"))
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+	page, err := pageRequest(request.URL.Query())
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, err.Error()).ToBytes())
+		return
+	}
+
+	items, info, err := s.userSvc.GetAllTasks(request.Context(), userID, expr, includeSnoozed, sorting, page)
+	if errors.Is(err, service.ErrInvalidRequest) {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Cursor").ToBytes())
 		return
 	}
 	if err != nil {
 		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
 		return
 	}
-	_, err = writer.Write(models.ResponseWrite("Tasks successfully retrieved!", items).ToBytes())
+	if commentCount {
+		err = s.userSvc.AddCommentCounts(request.Context(), items...)
+		if err != nil {
+			writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+			return
+		}
+	}
+	if wantsHTML(request.URL.Query()) {
+		renderTasks(items...)
+	}
+	response := paginate(models.ResponseWrite("Tasks successfully retrieved!", items), info)
+	// an empty page past the end of a non-empty result is not a failed search
+	if info.Total == 0 {
+		response.Suggest(s.didYouMean(request.Context(), userID, filter.Texts(expr)...))
+	}
+	_, err = writer.Write(response.ToBytes())
 
 	if err != nil {
 		lg.Error(err)
@@ -205,8 +310,12 @@ func (s *Server) handleUpdateTask(writer http.ResponseWriter, request *http.Requ
 
 	task.UpdatedAt = time.Now()
 
-	items, err := s.userSvc.UpdateTask(request.Context(), task, userID)
+	items, err := s.userSvc.UpdateTask(request.Context(), task, userID, ifMatchVersion(request, "task", task.ID))
 
+	if errors.Is(err, service.ErrPreconditionFailed) {
+		preconditionFailed(writer, versionETag("task", items.ID, items.Version), "Task Was Modified", items)
+		return
+	}
 	if errors.Is(err, service.ErrNotFound) {
 		writer.Write(models.ResponseError(http.StatusNotFound, http.StatusText(http.StatusNotFound)).ToBytes())
 		return
@@ -215,6 +324,7 @@ func (s *Server) handleUpdateTask(writer http.ResponseWriter, request *http.Requ
 		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
 		return
 	}
+	writer.Header().Set("ETag", versionETag("task", items.ID, items.Version))
 	_, err = writer.Write(models.ResponseWrite("Task successfully updated!", items).ToBytes())
 
 	if err != nil {
@@ -239,7 +349,11 @@ func (s *Server) handleMarkTaskAsCompeted(writer http.ResponseWriter, request *h
 	value := request.Context().Value(types.Key("key"))
 	userID := value.(int64)
 
-	items, err := s.userSvc.MarkAsCompleted(request.Context(), id, userID)
+	items, err := s.userSvc.MarkAsCompleted(request.Context(), id, userID, ifMatchVersion(request, "task", id))
+	if errors.Is(err, service.ErrPreconditionFailed) {
+		preconditionFailed(writer, versionETag("task", items.ID, items.Version), "Task Was Modified", items)
+		return
+	}
 	if errors.Is(err, service.ErrNotFound) {
 		writer.Write(models.ResponseError(http.StatusNotFound, http.StatusText(http.StatusNotFound)).ToBytes())
 		return
@@ -248,6 +362,7 @@ func (s *Server) handleMarkTaskAsCompeted(writer http.ResponseWriter, request *h
 		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
 		return
 	}
+	writer.Header().Set("ETag", versionETag("task", items.ID, items.Version))
 	_, err = writer.Write(models.ResponseWrite("Task marked as completed!", items).ToBytes())
 
 	if err != nil {
@@ -272,7 +387,11 @@ func (s *Server) handleMarkTaskAsCanceled(writer http.ResponseWriter, request *h
 	value := request.Context().Value(types.Key("key"))
 	userID := value.(int64)
 
-	items, err := s.userSvc.MarkAsCanceled(request.Context(), id, userID)
+	items, err := s.userSvc.MarkAsCanceled(request.Context(), id, userID, ifMatchVersion(request, "task", id))
+	if errors.Is(err, service.ErrPreconditionFailed) {
+		preconditionFailed(writer, versionETag("task", items.ID, items.Version), "Task Was Modified", items)
+		return
+	}
 	if errors.Is(err, service.ErrNotFound) {
 		writer.Write(models.ResponseError(http.StatusNotFound, http.StatusText(http.StatusNotFound)).ToBytes())
 		return
@@ -281,6 +400,7 @@ func (s *Server) handleMarkTaskAsCanceled(writer http.ResponseWriter, request *h
 		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
 		return
 	}
+	writer.Header().Set("ETag", versionETag("task", items.ID, items.Version))
 	_, err = writer.Write(models.ResponseWrite("Task marked as canceled!", items).ToBytes())
 
 	if err != nil {
@@ -315,6 +435,10 @@ func (s *Server) handleAddComment(writer http.ResponseWriter, request *http.Requ
 		return
 	}
 
+	if wantsHTML(request.URL.Query()) {
+		renderComments(items)
+	}
+	writer.Header().Set("ETag", versionETag("comment", items.ID, items.Version))
 	_, err = writer.Write(models.ResponseWrite("Comment added successfully!", items).ToBytes())
 
 	if err != nil {
@@ -352,12 +476,35 @@ func (s *Server) handleGetStatusAndTag(writer http.ResponseWriter, request *http
 
 	value := request.Context().Value(types.Key("key"))
 	userID := value.(int64)
-
-	items, err := s.userSvc.GetStatusAndTag(request.Context(), userID)
-	if errors.Is(err, service.ErrNotFound) {
-		writer.Write(models.ResponseError(http.StatusNotFound, http.StatusText(http.StatusNotFound)).ToBytes())
-		return
-	}
+	query := request.URL.Query()
+
+	filter := &models.TagStatusFilter{}
+	if projectParam := query.Get("project_id"); projectParam != "" {
+		projectID, err := strconv.ParseInt(projectParam, 10, 64)
+		if err != nil {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Project ID").ToBytes())
+			return
+		}
+		filter.ProjectID = &projectID
+	}
+	if fromParam := query.Get("from"); fromParam != "" {
+		from, err := parseTime(fromParam, time.Time{}, false)
+		if err != nil {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid From Date").ToBytes())
+			return
+		}
+		filter.From = &from
+	}
+	if toParam := query.Get("to"); toParam != "" {
+		to, err := parseTime(toParam, time.Time{}, true)
+		if err != nil || (filter.From != nil && !to.After(*filter.From)) {
+			writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid To Date").ToBytes())
+			return
+		}
+		filter.To = &to
+	}
+
+	items, err := s.userSvc.GetStatusAndTag(request.Context(), userID, filter)
 	if err != nil {
 		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
 		return
@@ -395,6 +542,17 @@ func (s *Server) handleGetTaskByID(writer http.ResponseWriter, request *http.Req
 		return
 	}
 
+	html := wantsHTML(request.URL.Query())
+	etag := representationETag("task", items.ID, items.Version, items.Checklist, items.Reactions, html)
+	writer.Header().Set("ETag", etag)
+	if notModified(request, etag) {
+		writer.WriteHeader(http.StatusNotModified)
+		return
+	}
+	if html {
+		renderTasks(items)
+	}
+
 	_, err = writer.Write(models.ResponseWrite("Task Successfully Retrieved!", items).ToBytes())
 
 	if err != nil {
@@ -418,7 +576,11 @@ func (s *Server) handleDeleteCommentByID(writer http.ResponseWriter, request *ht
 	value := request.Context().Value(types.Key("key"))
 	userID := value.(int64)
   
-	items, err := s.userSvc.DeleteCommentByID(request.Context(), id, userID)
+	items, err := s.userSvc.DeleteCommentByID(request.Context(), id, userID, ifMatchVersion(request, "comment", id))
+	if errors.Is(err, service.ErrPreconditionFailed) {
+		preconditionFailed(writer, versionETag("comment", items.ID, items.Version), "Comment Was Modified", items)
+		return
+	}
 	if errors.Is(err, service.ErrNotFound) {
 	  writer.Write(models.ResponseError(http.StatusNotFound, "Comment Not Found").ToBytes())
 	  return
@@ -451,8 +613,12 @@ func (s *Server) handleDeleteCommentByID(writer http.ResponseWriter, request *ht
 		return
 	}
 
-	items, err := s.userSvc.UpdateComment(request.Context(), comment, userID)
+	items, err := s.userSvc.UpdateComment(request.Context(), comment, userID, ifMatchVersion(request, "comment", comment.ID))
 
+	if errors.Is(err, service.ErrPreconditionFailed) {
+		preconditionFailed(writer, versionETag("comment", items.ID, items.Version), "Comment Was Modified", items)
+		return
+	}
 	if errors.Is(err, service.ErrNotFound) {
 		writer.Write(models.ResponseError(http.StatusNotFound, http.StatusText(http.StatusNotFound)).ToBytes())
 		return
@@ -461,6 +627,10 @@ func (s *Server) handleDeleteCommentByID(writer http.ResponseWriter, request *ht
 		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
 		return
 	}
+	if wantsHTML(request.URL.Query()) {
+		renderComments(items)
+	}
+	writer.Header().Set("ETag", versionETag("comment", items.ID, items.Version))
 	_, err = writer.Write(models.ResponseWrite("Comment successfully updated!", items).ToBytes())
 
 	if err != nil {
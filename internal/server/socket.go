@@ -0,0 +1,397 @@
+package server
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	// socketWriteWait bounds every write, a client that stops reading is dropped after it
+	socketWriteWait = 10 * time.Second
+
+	// socketPongWait is how long a silent client is kept, pings go out more often than that
+	socketPongWait   = 60 * time.Second
+	socketPingPeriod = socketPongWait * 9 / 10
+
+	// socketMaxMessage is the largest frame accepted from a client
+	socketMaxMessage = 4096
+
+	// socketSendBuffer is how many presence and typing frames wait for a slow client before new ones are dropped
+	socketSendBuffer = 64
+
+	// maxSocketTopics is how many topics one connection may watch
+	maxSocketTopics = 50
+)
+
+// typingTimeout ends a typing indicator the client did not refresh or stop
+var typingTimeout = 6 * time.Second
+
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// socketClient is one connection of the real-time channel, its topics and typing timers are guarded by the hub
+type socketClient struct {
+	user   *models.Author
+	send   chan *models.SocketMessage
+	topics map[string]bool
+	typing map[string]*time.Timer
+}
+
+// deliver queues a frame without blocking, presence and typing are only worth sending while current
+func (c *socketClient) deliver(msg *models.SocketMessage) {
+	select {
+	case c.send <- msg:
+	default:
+	}
+}
+
+// reply queues an answer to the client's own request, waiting for room
+func (c *socketClient) reply(ctx context.Context, msg *models.SocketMessage) {
+	select {
+	case c.send <- msg:
+	case <-ctx.Done():
+	}
+}
+
+// hub tracks who watches which topic on this instance, for presence and typing indicators.
+// It lives in the memory of one process: behind several instances, clients connected to different ones do not see
+// each other. Only changes travel between instances, through the database's NOTIFY; presence and typing do not
+type hub struct {
+	mu     sync.Mutex
+	topics map[string]map[*socketClient]bool
+}
+
+func newHub() *hub {
+	return &hub{topics: make(map[string]map[*socketClient]bool)}
+}
+
+// viewers lists the users watching the topic once each, the caller holds h.mu
+func (h *hub) viewers(topic string) []*models.Author {
+	seen := make(map[int64]bool)
+	items := make([]*models.Author, 0)
+	for c := range h.topics[topic] {
+		if !seen[c.user.ID] {
+			seen[c.user.ID] = true
+			items = append(items, c.user)
+		}
+	}
+	sort.Slice(items, func(i, j int) bool { return items[i].ID < items[j].ID })
+	return items
+}
+
+// viewing reports whether the user watches the topic through a connection other than c, the caller holds h.mu
+func (h *hub) viewing(topic string, c *socketClient) bool {
+	for other := range h.topics[topic] {
+		if other != c && other.user.ID == c.user.ID {
+			return true
+		}
+	}
+	return false
+}
+
+// broadcast sends the frame to the topic's clients except one, the caller holds h.mu
+func (h *hub) broadcast(topic string, msg *models.SocketMessage, except *socketClient) {
+	for c := range h.topics[topic] {
+		if c != except {
+			c.deliver(msg)
+		}
+	}
+}
+
+// subscribe adds the client to the topic and returns its viewers, the others learn about a new viewer
+func (h *hub) subscribe(c *socketClient, topic string) ([]*models.Author, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !c.topics[topic] {
+		if len(c.topics) >= maxSocketTopics {
+			return nil, false
+		}
+		if h.topics[topic] == nil {
+			h.topics[topic] = make(map[*socketClient]bool)
+		}
+		joined := !h.viewing(topic, c)
+		c.topics[topic] = true
+		h.topics[topic][c] = true
+		if joined {
+			h.broadcast(topic, &models.SocketMessage{Type: "presence", Topic: topic, Viewers: h.viewers(topic)}, c)
+		}
+	}
+	return h.viewers(topic), true
+}
+
+func (h *hub) unsubscribe(c *socketClient, topic string) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	h.remove(c, topic)
+}
+
+// remove takes the client off the topic, ending its typing indicator; the caller holds h.mu
+func (h *hub) remove(c *socketClient, topic string) {
+	if !c.topics[topic] {
+		return
+	}
+	h.stopTyping(c, topic)
+	delete(c.topics, topic)
+	delete(h.topics[topic], c)
+	if len(h.topics[topic]) == 0 {
+		delete(h.topics, topic)
+		return
+	}
+	if !h.viewing(topic, c) {
+		h.broadcast(topic, &models.SocketMessage{Type: "presence", Topic: topic, Viewers: h.viewers(topic)}, nil)
+	}
+}
+
+// leave takes the client off all its topics when the connection ends
+func (h *hub) leave(c *socketClient) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for topic := range c.topics {
+		h.remove(c, topic)
+	}
+}
+
+// setTyping starts, refreshes or stops the client's typing indicator on a watched topic
+func (h *hub) setTyping(c *socketClient, topic string, typing bool) bool {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	if !c.topics[topic] {
+		return false
+	}
+	if !typing {
+		h.stopTyping(c, topic)
+		return true
+	}
+
+	if timer, ok := c.typing[topic]; ok {
+		timer.Reset(typingTimeout)
+		return true
+	}
+	var timer *time.Timer
+	timer = time.AfterFunc(typingTimeout, func() {
+		h.mu.Lock()
+		defer h.mu.Unlock()
+		// a timer stopped too late must not end the indicator that replaced it
+		if c.typing[topic] == timer {
+			h.stopTyping(c, topic)
+		}
+	})
+	c.typing[topic] = timer
+	h.broadcast(topic, &models.SocketMessage{Type: "typing", Topic: topic, User: c.user, Typing: &typing}, c)
+	return true
+}
+
+// stopTyping ends the client's typing indicator if it is on, the caller holds h.mu
+func (h *hub) stopTyping(c *socketClient, topic string) {
+	timer, ok := c.typing[topic]
+	if !ok {
+		return
+	}
+	timer.Stop()
+	delete(c.typing, topic)
+	typing := false
+	h.broadcast(topic, &models.SocketMessage{Type: "typing", Topic: topic, User: c.user, Typing: &typing}, c)
+}
+
+// watching returns the topic through which the client watches the change, if any
+func (h *hub) watching(c *socketClient, change *models.Change) (string, bool) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	topic := "task:" + strconv.FormatInt(change.TaskID, 10)
+	if c.topics[topic] {
+		return topic, true
+	}
+	if change.ProjectID != nil {
+		topic = "project:" + strconv.FormatInt(*change.ProjectID, 10)
+		if c.topics[topic] {
+			return topic, true
+		}
+	}
+	return "", false
+}
+
+// parseTopic splits "task:<id>" or "project:<id>"
+func parseTopic(topic string) (string, int64, error) {
+	parts := strings.SplitN(topic, ":", 2)
+	if len(parts) != 2 || (parts[0] != "task" && parts[0] != "project") {
+		return "", 0, fmt.Errorf("invalid topic")
+	}
+	id, err := strconv.ParseInt(parts[1], 10, 64)
+	if err != nil || id <= 0 {
+		return "", 0, fmt.Errorf("invalid topic")
+	}
+	return parts[0], id, nil
+}
+
+// handleSocket upgrades to the real-time channel: clients subscribe to tasks and projects, see who else views them
+// and who is typing a comment, and receive the changes made to them through the API
+func (s *Server) handleSocket(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	user, err := s.userSvc.GetUserInfo(request.Context(), userID)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	conn, err := upgrader.Upgrade(writer, request, nil)
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+	defer conn.Close()
+
+	client := &socketClient{
+		user:   &models.Author{ID: user.ID, Username: user.Username},
+		send:   make(chan *models.SocketMessage, socketSendBuffer),
+		topics: make(map[string]bool),
+		typing: make(map[string]*time.Timer),
+	}
+	defer s.hub.leave(client)
+
+	ctx, cancel := context.WithCancel(request.Context())
+	defer cancel()
+
+	go s.readSocket(ctx, cancel, conn, client)
+	s.writeSocket(ctx, conn, client, userID)
+}
+
+// readSocket handles the client's frames until the connection fails or goes silent
+func (s *Server) readSocket(ctx context.Context, cancel context.CancelFunc, conn *websocket.Conn, client *socketClient) {
+	defer cancel()
+
+	conn.SetReadLimit(socketMaxMessage)
+	conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(socketPongWait))
+	})
+
+	for {
+		msg := &models.SocketMessage{}
+		err := conn.ReadJSON(msg)
+		if err != nil {
+			if websocket.IsUnexpectedCloseError(err, websocket.CloseNormalClosure, websocket.CloseGoingAway) {
+				lg.Error(err)
+			}
+			return
+		}
+		client.reply(ctx, s.handleSocketMessage(ctx, client, msg))
+	}
+}
+
+// handleSocketMessage answers one client frame
+func (s *Server) handleSocketMessage(ctx context.Context, client *socketClient, msg *models.SocketMessage) *models.SocketMessage {
+	switch msg.Type {
+	case "ping":
+		return &models.SocketMessage{Type: "pong"}
+	case "subscribe":
+		kind, id, err := parseTopic(msg.Topic)
+		if err != nil {
+			return &models.SocketMessage{Type: "error", Topic: msg.Topic, Message: "Invalid Topic"}
+		}
+		err = s.canWatch(ctx, client.user.ID, kind, id)
+		if errors.Is(err, service.ErrNotFound) {
+			return &models.SocketMessage{Type: "error", Topic: msg.Topic, Message: "Topic Not Found"}
+		}
+		if err != nil {
+			return &models.SocketMessage{Type: "error", Topic: msg.Topic, Message: http.StatusText(http.StatusInternalServerError)}
+		}
+		viewers, ok := s.hub.subscribe(client, msg.Topic)
+		if !ok {
+			return &models.SocketMessage{Type: "error", Topic: msg.Topic, Message: "Too Many Topics"}
+		}
+		return &models.SocketMessage{Type: "subscribed", Topic: msg.Topic, Viewers: viewers}
+	case "unsubscribe":
+		s.hub.unsubscribe(client, msg.Topic)
+		return &models.SocketMessage{Type: "unsubscribed", Topic: msg.Topic}
+	case "typing":
+		kind, _, err := parseTopic(msg.Topic)
+		if err != nil || kind != "task" || msg.Typing == nil {
+			return &models.SocketMessage{Type: "error", Topic: msg.Topic, Message: "Typing Is Only Shown On Tasks"}
+		}
+		if !s.hub.setTyping(client, msg.Topic, *msg.Typing) {
+			return &models.SocketMessage{Type: "error", Topic: msg.Topic, Message: "Not Subscribed"}
+		}
+		return &models.SocketMessage{Type: "typing", Topic: msg.Topic, User: client.user, Typing: msg.Typing}
+	}
+	return &models.SocketMessage{Type: "error", Message: "Unknown Message Type"}
+}
+
+// writeSocket is the only writer of the connection: it sends queued frames, pings, and the changes to watched topics.
+// Changes are read from the log at the pace the client takes them, as for the event stream
+func (s *Server) writeSocket(ctx context.Context, conn *websocket.Conn, client *socketClient, userID int64) {
+	signal, unsubscribe := s.userSvc.SubscribeChanges(userID)
+	defer unsubscribe()
+
+	cursor, _, err := s.userSvc.ChangeCursor(ctx, userID, nil)
+	if err != nil {
+		return
+	}
+
+	ping := time.NewTicker(socketPingPeriod)
+	defer ping.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			conn.WriteControl(websocket.CloseMessage, websocket.FormatCloseMessage(websocket.CloseNormalClosure, ""), time.Now().Add(socketWriteWait))
+			return
+		case msg := <-client.send:
+			conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			err = conn.WriteJSON(msg)
+		case <-signal:
+			cursor, err = s.writeChanges(ctx, conn, client, userID, cursor)
+		case <-ping.C:
+			err = conn.WriteControl(websocket.PingMessage, nil, time.Now().Add(socketWriteWait))
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// writeChanges sends the user's changes after the cursor that touch a watched topic and returns the new cursor
+func (s *Server) writeChanges(ctx context.Context, conn *websocket.Conn, client *socketClient, userID int64, cursor int64) (int64, error) {
+	for {
+		items, err := s.userSvc.GetChangesAfter(ctx, userID, cursor, streamBatch)
+		if err != nil {
+			return cursor, err
+		}
+		for _, item := range items {
+			cursor = item.ID
+			topic, ok := s.hub.watching(client, item)
+			if !ok {
+				continue
+			}
+			conn.SetWriteDeadline(time.Now().Add(socketWriteWait))
+			err = conn.WriteJSON(&models.SocketMessage{Type: "change", Topic: topic, Change: item})
+			if err != nil {
+				return cursor, err
+			}
+		}
+		if len(items) < streamBatch {
+			return cursor, nil
+		}
+	}
+}
@@ -0,0 +1,238 @@
+package server
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/service"
+	"github.com/gorilla/websocket"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+// testUsers are the users of the test socket server; task 1 can be read by alice and bob, not by carol
+var testUsers = map[int64]string{1: "alice", 2: "bob", 3: "carol"}
+
+// testSocketServer serves the socket's frames without the database: the user comes from the "user" query parameter,
+// topics are authorized as if only alice and bob could read task 1, and no changes are sent
+func testSocketServer(t *testing.T) string {
+	s := &Server{hub: newHub(), canWatch: func(ctx context.Context, userID int64, kind string, id int64) error {
+		if kind != "task" || id != 1 || userID == 3 {
+			return service.ErrNotFound
+		}
+		return nil
+	}}
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		userID, _ := strconv.ParseInt(request.URL.Query().Get("user"), 10, 64)
+		conn, err := upgrader.Upgrade(writer, request, nil)
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+
+		client := &socketClient{
+			user:   &models.Author{ID: userID, Username: testUsers[userID]},
+			send:   make(chan *models.SocketMessage, socketSendBuffer),
+			topics: make(map[string]bool),
+			typing: make(map[string]*time.Timer),
+		}
+		defer s.hub.leave(client)
+
+		ctx, cancel := context.WithCancel(request.Context())
+		defer cancel()
+
+		go s.readSocket(ctx, cancel, conn, client)
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case msg := <-client.send:
+				if conn.WriteJSON(msg) != nil {
+					return
+				}
+			}
+		}
+	}))
+	t.Cleanup(server.Close)
+
+	return "ws" + strings.TrimPrefix(server.URL, "http")
+}
+
+func dialSocket(t *testing.T, url string, userID int64) *websocket.Conn {
+	t.Helper()
+	conn, _, err := websocket.DefaultDialer.Dial(url+"?user="+strconv.FormatInt(userID, 10), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+	return conn
+}
+
+func readFrame(t *testing.T, conn *websocket.Conn) *models.SocketMessage {
+	t.Helper()
+	conn.SetReadDeadline(time.Now().Add(2 * time.Second))
+	msg := &models.SocketMessage{}
+	err := conn.ReadJSON(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return msg
+}
+
+func sendFrame(t *testing.T, conn *websocket.Conn, msg *models.SocketMessage) {
+	t.Helper()
+	err := conn.WriteJSON(msg)
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// noFrame checks that nothing arrives for a while: a read that times out breaks the connection, so after waiting
+// the answer to a ping has to be the next frame
+func noFrame(t *testing.T, conn *websocket.Conn, wait time.Duration) {
+	t.Helper()
+	time.Sleep(wait)
+	sendFrame(t, conn, &models.SocketMessage{Type: "ping"})
+	if msg := readFrame(t, conn); msg.Type != "pong" {
+		t.Fatalf("unexpected %s frame", msg.Type)
+	}
+}
+
+func viewerNames(msg *models.SocketMessage) string {
+	names := make([]string, 0, len(msg.Viewers))
+	for _, viewer := range msg.Viewers {
+		names = append(names, viewer.Username)
+	}
+	return strings.Join(names, ",")
+}
+
+func TestSocketPresence(t *testing.T) {
+	url := testSocketServer(t)
+	alice := dialSocket(t, url, 1)
+	bob := dialSocket(t, url, 2)
+
+	sendFrame(t, alice, &models.SocketMessage{Type: "subscribe", Topic: "task:1"})
+	if msg := readFrame(t, alice); msg.Type != "subscribed" || viewerNames(msg) != "alice" {
+		t.Fatalf("alice subscribing gave %s with viewers %s", msg.Type, viewerNames(msg))
+	}
+
+	sendFrame(t, bob, &models.SocketMessage{Type: "subscribe", Topic: "task:1"})
+	if msg := readFrame(t, bob); msg.Type != "subscribed" || viewerNames(msg) != "alice,bob" {
+		t.Fatalf("bob subscribing gave %s with viewers %s", msg.Type, viewerNames(msg))
+	}
+	if msg := readFrame(t, alice); msg.Type != "presence" || msg.Topic != "task:1" || viewerNames(msg) != "alice,bob" {
+		t.Fatalf("alice got %s with viewers %s when bob came", msg.Type, viewerNames(msg))
+	}
+
+	// a user who cannot read the task is refused and nobody sees them
+	carol := dialSocket(t, url, 3)
+	sendFrame(t, carol, &models.SocketMessage{Type: "subscribe", Topic: "task:1"})
+	if msg := readFrame(t, carol); msg.Type != "error" || msg.Message != "Topic Not Found" {
+		t.Fatalf("carol subscribing gave %s %q", msg.Type, msg.Message)
+	}
+
+	// another tab of bob changes nobody's list
+	bobTab := dialSocket(t, url, 2)
+	sendFrame(t, bobTab, &models.SocketMessage{Type: "subscribe", Topic: "task:1"})
+	if msg := readFrame(t, bobTab); msg.Type != "subscribed" || viewerNames(msg) != "alice,bob" {
+		t.Fatalf("bob's second tab got %s with viewers %s", msg.Type, viewerNames(msg))
+	}
+	bobTab.Close()
+	noFrame(t, alice, 200*time.Millisecond)
+
+	sendFrame(t, bob, &models.SocketMessage{Type: "unsubscribe", Topic: "task:1"})
+	if msg := readFrame(t, bob); msg.Type != "unsubscribed" {
+		t.Fatalf("unsubscribing gave %s", msg.Type)
+	}
+	if msg := readFrame(t, alice); msg.Type != "presence" || viewerNames(msg) != "alice" {
+		t.Fatalf("alice got %s with viewers %s when bob unsubscribed", msg.Type, viewerNames(msg))
+	}
+
+	sendFrame(t, bob, &models.SocketMessage{Type: "subscribe", Topic: "task:1"})
+	readFrame(t, bob)
+	readFrame(t, alice)
+	bob.Close()
+	if msg := readFrame(t, alice); msg.Type != "presence" || viewerNames(msg) != "alice" {
+		t.Fatalf("alice got %s with viewers %s when bob disconnected", msg.Type, viewerNames(msg))
+	}
+}
+
+func TestSocketErrors(t *testing.T) {
+	url := testSocketServer(t)
+	conn := dialSocket(t, url, 1)
+
+	tests := []struct {
+		msg  *models.SocketMessage
+		want string
+	}{
+		{&models.SocketMessage{Type: "subscribe", Topic: "task:x"}, "Invalid Topic"},
+		{&models.SocketMessage{Type: "subscribe", Topic: "task:2"}, "Topic Not Found"},
+		{&models.SocketMessage{Type: "typing", Topic: "project:1", Typing: new(bool)}, "Typing Is Only Shown On Tasks"},
+		{&models.SocketMessage{Type: "typing", Topic: "task:1", Typing: new(bool)}, "Not Subscribed"},
+		{&models.SocketMessage{Type: "shout"}, "Unknown Message Type"},
+	}
+	for _, test := range tests {
+		sendFrame(t, conn, test.msg)
+		if msg := readFrame(t, conn); msg.Type != "error" || msg.Message != test.want {
+			t.Errorf("%s %s gave %s %q, want error %q", test.msg.Type, test.msg.Topic, msg.Type, msg.Message, test.want)
+		}
+	}
+
+	sendFrame(t, conn, &models.SocketMessage{Type: "ping"})
+	if msg := readFrame(t, conn); msg.Type != "pong" {
+		t.Errorf("ping gave %s", msg.Type)
+	}
+}
+
+func TestSocketTyping(t *testing.T) {
+	defer func(timeout time.Duration) { typingTimeout = timeout }(typingTimeout)
+	typingTimeout = 300 * time.Millisecond
+
+	url := testSocketServer(t)
+	alice := dialSocket(t, url, 1)
+	bob := dialSocket(t, url, 2)
+	sendFrame(t, alice, &models.SocketMessage{Type: "subscribe", Topic: "task:1"})
+	readFrame(t, alice)
+	sendFrame(t, bob, &models.SocketMessage{Type: "subscribe", Topic: "task:1"})
+	readFrame(t, bob)
+	readFrame(t, alice)
+
+	typing := true
+	sendFrame(t, bob, &models.SocketMessage{Type: "typing", Topic: "task:1", Typing: &typing})
+	if msg := readFrame(t, bob); msg.Type != "typing" || msg.Typing == nil || !*msg.Typing {
+		t.Fatalf("starting to type gave %s", msg.Type)
+	}
+	started := time.Now()
+	if msg := readFrame(t, alice); msg.Type != "typing" || msg.User == nil || msg.User.Username != "bob" || !*msg.Typing {
+		t.Fatalf("alice got %s when bob started typing", msg.Type)
+	}
+
+	// a refresh keeps the indicator on without another frame to the others
+	time.Sleep(150 * time.Millisecond)
+	sendFrame(t, bob, &models.SocketMessage{Type: "typing", Topic: "task:1", Typing: &typing})
+	readFrame(t, bob)
+
+	msg := readFrame(t, alice)
+	if msg.Type != "typing" || msg.User.Username != "bob" || *msg.Typing {
+		t.Fatalf("the indicator ended with %s", msg.Type)
+	}
+	if elapsed := time.Since(started); elapsed < 400*time.Millisecond {
+		t.Errorf("the refreshed indicator ended after %v", elapsed)
+	}
+
+	sendFrame(t, bob, &models.SocketMessage{Type: "typing", Topic: "task:1", Typing: &typing})
+	readFrame(t, bob)
+	readFrame(t, alice)
+	bob.Close()
+	if msg := readFrame(t, alice); msg.Type != "typing" || *msg.Typing {
+		t.Fatalf("disconnecting while typing gave %s", msg.Type)
+	}
+	if msg := readFrame(t, alice); msg.Type != "presence" || viewerNames(msg) != "alice" {
+		t.Fatalf("disconnecting gave %s with viewers %s", msg.Type, viewerNames(msg))
+	}
+	noFrame(t, alice, typingTimeout+100*time.Millisecond)
+}
@@ -0,0 +1,137 @@
+package server
+
+import (
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/pkg/types"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const (
+	// streamHeartbeat is how often an idle stream is pinged so proxies keep it open
+	streamHeartbeat = 15 * time.Second
+
+	// streamBatch is how many changes are read from the log at once
+	streamBatch = 100
+
+	// streamRetry is the reconnection delay suggested to clients, in milliseconds
+	streamRetry = 3000
+)
+
+// lastEventID is the id a reconnecting client has seen last, from the Last-Event-ID header or the last_event_id parameter
+func lastEventID(request *http.Request) (*int64, error) {
+	value := request.Header.Get("Last-Event-ID")
+	if value == "" {
+		value = request.URL.Query().Get("last_event_id")
+	}
+	if value == "" {
+		return nil, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		return nil, fmt.Errorf("invalid last event id")
+	}
+	return &id, nil
+}
+
+// writeChange writes the change as one event, the payload is the JSON of the changed task or comment
+func writeChange(writer http.ResponseWriter, change *models.Change) error {
+	data := strings.ReplaceAll(string(change.Data), "\n", "\ndata: ")
+	_, err := fmt.Fprintf(writer, "id: %d\nevent: %s\ndata: %s\n\n", change.ID, change.Type, data)
+	return err
+}
+
+// handleEvents streams the user's task and comment changes as server-sent events.
+// Changes are read from the log at the pace the client takes them, so a slow client only falls behind
+// in the log instead of piling up in memory, and a reconnecting one resumes after its Last-Event-ID
+func (s *Server) handleEvents(writer http.ResponseWriter, request *http.Request) {
+	writer.Header().Set("Content-Type", "application/json")
+
+	value := request.Context().Value(types.Key("key"))
+	userID := value.(int64)
+
+	flusher, ok := writer.(http.Flusher)
+	if !ok {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, "Streaming Unsupported").ToBytes())
+		return
+	}
+
+	lastID, err := lastEventID(request)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusBadRequest, "Invalid Last Event ID").ToBytes())
+		return
+	}
+
+	// subscribe before reading the cursor, so that nothing committed in between goes unnoticed
+	signal, unsubscribe := s.userSvc.SubscribeChanges(userID)
+	defer unsubscribe()
+
+	cursor, gap, err := s.userSvc.ChangeCursor(request.Context(), userID, lastID)
+	if err != nil {
+		writer.Write(models.ResponseError(http.StatusInternalServerError, http.StatusText(http.StatusInternalServerError)).ToBytes())
+		return
+	}
+
+	writer.Header().Set("Content-Type", "text/event-stream")
+	writer.Header().Set("Cache-Control", "no-cache")
+	writer.Header().Set("Connection", "keep-alive")
+	writer.Header().Set("X-Accel-Buffering", "no")
+	writer.WriteHeader(http.StatusOK)
+
+	_, err = fmt.Fprintf(writer, "retry: %d\n\n", streamRetry)
+	if err != nil {
+		return
+	}
+	if gap {
+		// the changes after lastID were pruned already, the client has to reload and continue from here
+		cursor, _, err = s.userSvc.ChangeCursor(request.Context(), userID, nil)
+		if err != nil {
+			return
+		}
+		_, err = fmt.Fprintf(writer, "id: %d\nevent: reset\ndata: {}\n\n", cursor)
+		if err != nil {
+			return
+		}
+	}
+	flusher.Flush()
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+
+	for {
+		for {
+			items, err := s.userSvc.GetChangesAfter(request.Context(), userID, cursor, streamBatch)
+			if err != nil {
+				return
+			}
+			for _, item := range items {
+				err = writeChange(writer, item)
+				if err != nil {
+					return
+				}
+				cursor = item.ID
+			}
+			if len(items) > 0 {
+				flusher.Flush()
+			}
+			if len(items) < streamBatch {
+				break
+			}
+		}
+
+		select {
+		case <-request.Context().Done():
+			return
+		case <-signal:
+		case <-heartbeat.C:
+			_, err = fmt.Fprint(writer, ": heartbeat\n\n")
+			if err != nil {
+				return
+			}
+			flusher.Flush()
+		}
+	}
+}
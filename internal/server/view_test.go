@@ -0,0 +1,42 @@
+package server
+
+import (
+	"encoding/json"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"testing"
+)
+
+func TestProjectTasks(t *testing.T) {
+	items := []*models.Task{
+		{ID: 1, Title: "Write report", Description: "**draft**", DescriptionHTML: "draft", Priority: 3, Tags: []string{"work"}},
+		{ID: 2, Title: "Call home", Checklist: &models.ChecklistProgress{Total: 2, Checked: 1}},
+	}
+
+	whole, err := projectTasks(items, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if _, ok := whole.([]*models.Task); !ok {
+		t.Errorf("a view without columns changed the tasks to %T", whole)
+	}
+
+	projected, err := projectTasks(items, []string{"title", "description", "checklist"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := json.Marshal(projected)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `[{"description":"**draft**","description_html":"draft","id":1,"title":"Write report"},` +
+		`{"checklist":{"total":2,"checked":1},"description":"","id":2,"title":"Call home"}]`
+	if string(data) != want {
+		t.Errorf("projected =\n\t%s\nwant\n\t%s", data, want)
+	}
+
+	projected, _ = projectTasks(items, []string{"priority"})
+	data, _ = json.Marshal(projected)
+	if want := `[{"id":1,"priority":3},{"id":2,"priority":0}]`; string(data) != want {
+		t.Errorf("projected = %s, want %s", data, want)
+	}
+}
@@ -0,0 +1,281 @@
+package service
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// ErrTooLarge if an upload is bigger than allowed
+var ErrTooLarge = errors.New("file too large")
+
+const attachmentColumns = "a.id, a.task_id, a.comment_id, a.user_id, a.filename, a.content_type, a.size, a.sha256, a.created_at"
+
+func scanAttachment(row pgx.Row, attachment *models.Attachment) error {
+	return row.Scan(&attachment.ID, &attachment.TaskID, &attachment.CommentID, &attachment.UserID, &attachment.Filename, &attachment.ContentType, &attachment.Size, &attachment.SHA256, &attachment.CreatedAt)
+}
+
+// AddAttachment stores the content in the blob store under its SHA-256, so identical files are kept once
+func (s *Service) AddAttachment(ctx context.Context, item *models.Attachment, content io.Reader, maxSize int64, userID int64) (*models.Attachment, error) {
+	_, err := s.GetTaskByID(ctx, userID, item.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	spool, err := os.CreateTemp("", "attachment-")
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer os.Remove(spool.Name())
+	defer spool.Close()
+
+	hash := sha256.New()
+	size, err := io.Copy(io.MultiWriter(spool, hash), io.LimitReader(content, maxSize+1))
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	if size > maxSize {
+		return nil, ErrTooLarge
+	}
+	sum := hex.EncodeToString(hash.Sum(nil))
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockBlob(ctx, tx, sum)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	exists, err := s.store.Exists(ctx, sum)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	if !exists {
+		_, err = spool.Seek(0, io.SeekStart)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		err = s.store.Put(ctx, sum, spool, size)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO blobs (sha256) VALUES ($1) ON CONFLICT DO NOTHING;`, sum)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	attachment := &models.Attachment{}
+	err = scanAttachment(tx.QueryRow(ctx, `INSERT INTO attachments AS a (task_id, comment_id, user_id, filename, content_type, size, sha256)
+		VALUES ($1, (SELECT id FROM comments WHERE id=$2 AND task_id=$1), $3, $4, $5, $6, $7) RETURNING `+attachmentColumns+`;`,
+		item.TaskID, item.CommentID, userID, item.Filename, item.ContentType, size, sum), attachment)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return attachment, nil
+}
+
+// GetTaskAttachments method
+func (s *Service) GetTaskAttachments(ctx context.Context, taskID int64, userID int64) ([]*models.Attachment, error) {
+	_, err := s.GetTaskByID(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*models.Attachment, 0)
+	rows, err := s.pool.Query(ctx, `SELECT `+attachmentColumns+` FROM attachments a WHERE a.task_id=$1 ORDER BY a.id;`, taskID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.Attachment{}
+		err := scanAttachment(rows, item)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetAttachmentByID returns the attachment if its task belongs to the user
+func (s *Service) GetAttachmentByID(ctx context.Context, id int64, userID int64) (*models.Attachment, error) {
+	attachment := &models.Attachment{}
+	err := scanAttachment(s.pool.QueryRow(ctx, `SELECT `+attachmentColumns+` FROM attachments a INNER JOIN tasks t ON t.id=a.task_id WHERE a.id=$1 and t.user_id=$2;`, id, userID), attachment)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return attachment, nil
+}
+
+// OpenAttachment returns the attachment with a reader over its content
+func (s *Service) OpenAttachment(ctx context.Context, id int64, userID int64) (*models.Attachment, io.ReadSeekCloser, error) {
+	attachment, err := s.GetAttachmentByID(ctx, id, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	content, err := s.store.Get(ctx, attachment.SHA256)
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	return attachment, content, nil
+}
+
+// DeleteAttachmentByID removes the attachment and its blob once no other attachment shares it
+func (s *Service) DeleteAttachmentByID(ctx context.Context, id int64, userID int64) (*models.Attachment, error) {
+	attachment := &models.Attachment{}
+	err := scanAttachment(s.pool.QueryRow(ctx, `DELETE FROM attachments a USING tasks t WHERE a.id=$1 and t.id=a.task_id and t.user_id=$2 RETURNING `+attachmentColumns+`;`, id, userID), attachment)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	err = s.releaseBlob(ctx, attachment.SHA256)
+	if err != nil {
+		lg.Error(err)
+	}
+
+	return attachment, nil
+}
+
+// lockBlob makes uploads and removals of the same content take turns until the transaction ends,
+// so an upload never links to a blob that is being deleted
+func lockBlob(ctx context.Context, tx querier, sum string) error {
+	_, err := tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('blobs'), hashtext($1));`, sum)
+	if err != nil {
+		lg.Error(err)
+	}
+	return err
+}
+
+// releaseBlob deletes the blob once no attachment refers to it anymore
+func (s *Service) releaseBlob(ctx context.Context, sum string) error {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback(ctx)
+
+	err = lockBlob(ctx, tx, sum)
+	if err != nil {
+		return err
+	}
+
+	var shared bool
+	err = tx.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM attachments WHERE sha256=$1);`, sum).Scan(&shared)
+	if err != nil || shared {
+		return err
+	}
+
+	err = s.store.Delete(ctx, sum)
+	if err != nil {
+		return err
+	}
+	_, err = tx.Exec(ctx, `DELETE FROM blobs WHERE sha256=$1;`, sum)
+	if err != nil {
+		return err
+	}
+	return tx.Commit(ctx)
+}
+
+// SweepBlobs deletes up to limit blobs left without attachments, e.g. after their task or comment was deleted
+func (s *Service) SweepBlobs(ctx context.Context, limit int64) (int64, error) {
+	rows, err := s.pool.Query(ctx, `SELECT b.sha256 FROM blobs b WHERE NOT EXISTS (SELECT 1 FROM attachments a WHERE a.sha256=b.sha256) LIMIT $1;`, limit)
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+
+	sums := make([]string, 0)
+	for rows.Next() {
+		var sum string
+		err = rows.Scan(&sum)
+		if err != nil {
+			rows.Close()
+			lg.Error(err)
+			return 0, ErrInternal
+		}
+		sums = append(sums, sum)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+
+	var swept int64
+	for _, sum := range sums {
+		err = s.releaseBlob(ctx, sum)
+		if err != nil {
+			lg.Error(err)
+			continue
+		}
+		swept++
+	}
+	return swept, nil
+}
+
+// RunBlobSweeper sweeps unreferenced blobs every interval until ctx is done
+func (s *Service) RunBlobSweeper(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			swept, err := s.SweepBlobs(ctx, 100)
+			if err == nil && swept > 0 {
+				lg.Info("swept blobs: " + strconv.FormatInt(swept, 10))
+			}
+		}
+	}
+}
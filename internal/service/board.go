@@ -0,0 +1,94 @@
+package service
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"strings"
+)
+
+// boardScope restricts tasks to the user ($1), an optional project ($2) and an optional tag ($3)
+const boardScope = "user_id=$1 AND ($2::INT IS NULL OR project_id=$2) AND ($3 = '' OR EXISTS (SELECT 1 FROM task_tags tt INNER JOIN tags tg ON tg.id=tt.tag_id WHERE tt.task_id=tasks.id AND tg.name=$3))"
+
+// GetBoard groups the user's tasks into one column per status, each column paged on its own
+func (s *Service) GetBoard(ctx context.Context, query *models.BoardQuery, userID int64) (*models.Board, error) {
+	tag := strings.ToLower(query.Tag)
+
+	rows, err := s.pool.Query(ctx, `SELECT s.id, s.name, s.code_name, count(t.id) FROM status s
+		LEFT JOIN (SELECT id, status_id FROM tasks WHERE `+boardScope+`) t ON t.status_id=s.id
+		GROUP BY s.id, s.name, s.code_name
+		ORDER BY CASE s.code_name WHEN 'new' THEN 1 WHEN 'in_progress' THEN 2 WHEN 'completed' THEN 3 ELSE 4 END, s.id;`, userID, query.ProjectID, tag)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	board := &models.Board{Columns: make([]*models.BoardColumn, 0)}
+	for rows.Next() {
+		column := &models.BoardColumn{Status: &models.Status{}, PerPage: query.PerPage, Page: 1}
+		err := rows.Scan(&column.Status.ID, &column.Status.Name, &column.Status.CodeName, &column.Count)
+		if err != nil {
+			rows.Close()
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		if page, ok := query.Pages[column.Status.CodeName]; ok && page > 0 {
+			column.Page = page
+		}
+		board.Columns = append(board.Columns, column)
+	}
+	rows.Close()
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	for _, column := range board.Columns {
+		column.Cards, err = s.boardCards(ctx, query, tag, column, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	return board, nil
+}
+
+func (s *Service) boardCards(ctx context.Context, query *models.BoardQuery, tag string, column *models.BoardColumn, userID int64) ([]*models.Task, error) {
+	cards := make([]*models.Task, 0)
+	if (column.Page-1)*column.PerPage >= column.Count {
+		return cards, nil
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT `+taskColumns+` FROM tasks WHERE `+boardScope+` AND status_id=$4
+		ORDER BY priority DESC, due_at ASC NULLS LAST, id
+		LIMIT $5 OFFSET $6;`, userID, query.ProjectID, tag, column.Status.ID, column.PerPage, (column.Page-1)*column.PerPage)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		card := &models.Task{}
+		err := scanTask(rows, card)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		cards = append(cards, card)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	err = s.addChecklistProgress(ctx, cards...)
+	if err != nil {
+		return nil, err
+	}
+	return cards, nil
+}
@@ -0,0 +1,210 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+)
+
+// MaxBulkItems is the largest number of tasks a single bulk request may touch
+const MaxBulkItems = 500
+
+// Bulk actions
+const (
+	BulkComplete    = "complete"
+	BulkCancel      = "cancel"
+	BulkDelete      = "delete"
+	BulkAddTag      = "add_tag"
+	BulkRemoveTag   = "remove_tag"
+	BulkMoveProject = "move_to_project"
+	BulkSetPriority = "set_priority"
+)
+
+// bulkQueries hold the per-task statement of every action; $1 is the task id, $2 the user id and $3 the action argument
+var bulkQueries = map[string]string{
+	BulkComplete:    `UPDATE tasks SET status_id=1, updated_at=NOW(), version=version+1 WHERE id=$1 and user_id=$2 RETURNING ` + taskColumns + `;`,
+	BulkCancel:      `UPDATE tasks SET status_id=2, updated_at=NOW(), version=version+1 WHERE id=$1 and user_id=$2 RETURNING ` + taskColumns + `;`,
+	BulkDelete:      `DELETE FROM tasks WHERE id=$1 and user_id=$2 RETURNING ` + taskColumns + `;`,
+	BulkAddTag:      `UPDATE tasks SET updated_at=NOW(), version=version+1 WHERE id=$1 and user_id=$2 RETURNING ` + taskColumns + `;`,
+	BulkRemoveTag:   `UPDATE tasks SET updated_at=NOW(), version=version+1 WHERE id=$1 and user_id=$2 RETURNING ` + taskColumns + `;`,
+	BulkMoveProject: `UPDATE tasks SET project_id=$3, updated_at=NOW(), version=version+1 WHERE id=$1 and user_id=$2 RETURNING ` + taskColumns + `;`,
+	BulkSetPriority: `UPDATE tasks SET priority=$3, updated_at=NOW(), version=version+1 WHERE id=$1 and user_id=$2 RETURNING ` + taskColumns + `;`,
+}
+
+// bulkEvents are the changes recorded for every task an action touched
+var bulkEvents = map[string]string{
+	BulkComplete:    WebhookTaskCompleted,
+	BulkCancel:      WebhookTaskCanceled,
+	BulkDelete:      WebhookTaskDeleted,
+	BulkAddTag:      WebhookTaskUpdated,
+	BulkRemoveTag:   WebhookTaskUpdated,
+	BulkMoveProject: WebhookTaskUpdated,
+	BulkSetPriority: WebhookTaskUpdated,
+}
+
+// bulkPrepare holds statements that run before the action's query with the same arguments; the query then gets only $1 and $2
+var bulkPrepare = map[string]string{
+	BulkAddTag:    `INSERT INTO task_tags (task_id, tag_id) SELECT id, $3 FROM tasks WHERE id=$1 and user_id=$2 ON CONFLICT DO NOTHING;`,
+	BulkRemoveTag: `DELETE FROM task_tags tt USING tasks t WHERE tt.task_id=$1 and t.id=tt.task_id and t.user_id=$2 and tt.tag_id=$3;`,
+}
+
+// BulkTasks applies one action to many tasks in a single transaction and reports the outcome per task
+func (s *Service) BulkTasks(ctx context.Context, item *models.BulkRequest, userID int64) (*models.BulkResult, error) {
+	query, ok := bulkQueries[item.Action]
+	if !ok {
+		return nil, ErrInvalidRequest
+	}
+
+	var extra []interface{}
+	tag := normalizeTag(item.Tag)
+	switch item.Action {
+	case BulkAddTag, BulkRemoveTag:
+		if tag == "" {
+			return nil, ErrInvalidRequest
+		}
+	case BulkMoveProject:
+		extra = append(extra, item.ProjectID)
+	case BulkSetPriority:
+		if item.Priority < models.PriorityNone || item.Priority > models.PriorityUrgent {
+			return nil, ErrInvalidRequest
+		}
+		extra = append(extra, item.Priority)
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	switch item.Action {
+	case BulkAddTag:
+		tagID, err := ensureTag(ctx, tx, userID, tag)
+		if err != nil {
+			return nil, ErrInternal
+		}
+		extra = append(extra, tagID)
+	case BulkRemoveTag:
+		var tagID int64
+		err = tx.QueryRow(ctx, `SELECT id FROM tags WHERE user_id=$1 and name=$2;`, userID, tag).Scan(&tagID)
+		if err != nil && !errors.Is(err, pgx.ErrNoRows) {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		extra = append(extra, tagID)
+	}
+
+	if item.Action == BulkMoveProject && item.ProjectID != nil {
+		var projectID int64
+		err = tx.QueryRow(ctx, `SELECT id FROM projects WHERE id=$1 and user_id=$2;`, *item.ProjectID, userID).Scan(&projectID)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil, ErrNotFound
+		}
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+	}
+
+	ids := item.IDs
+	if len(ids) == 0 {
+		ids, err = s.bulkFilterIDs(ctx, tx, item.Filter, userID)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if len(ids) > MaxBulkItems {
+		return nil, ErrInvalidRequest
+	}
+
+	result := &models.BulkResult{Action: item.Action, Items: make([]*models.BulkItemResult, 0, len(ids))}
+	for _, id := range ids {
+		itemResult := &models.BulkItemResult{ID: id}
+		task := &models.Task{}
+		args := append([]interface{}{id, userID}, extra...)
+		if prepare, ok := bulkPrepare[item.Action]; ok {
+			done, err := tx.Exec(ctx, prepare, args...)
+			if err != nil {
+				lg.Error(err)
+				return nil, ErrInternal
+			}
+			args = args[:2]
+
+			// a task without the tag is left as it is, without a new version or change
+			if item.Action == BulkRemoveTag && done.RowsAffected() == 0 {
+				itemResult.Error = ErrNotFound.Error()
+				result.Failed++
+				result.Items = append(result.Items, itemResult)
+				continue
+			}
+		}
+
+		err = scanTask(tx.QueryRow(ctx, query, args...), task)
+
+		switch {
+		case errors.Is(err, pgx.ErrNoRows):
+			itemResult.Error = ErrNotFound.Error()
+			result.Failed++
+		case err != nil:
+			lg.Error(err)
+			return nil, ErrInternal
+		default:
+			err = recordChange(ctx, tx, userID, task.ID, task.ProjectID, bulkEvents[item.Action], task)
+			if err != nil {
+				return nil, ErrInternal
+			}
+			itemResult.OK = true
+			itemResult.Task = task
+			result.Succeeded++
+		}
+		result.Items = append(result.Items, itemResult)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return result, nil
+}
+
+// bulkFilterIDs resolves the filter of a bulk request to the ids of matching tasks, locking them for the transaction
+func (s *Service) bulkFilterIDs(ctx context.Context, tx pgx.Tx, filter *models.BulkFilter, userID int64) ([]int64, error) {
+	if filter == nil || (filter.Status == "" && filter.Tag == "" && filter.ProjectID == nil) {
+		return nil, ErrInvalidRequest
+	}
+
+	rows, err := tx.Query(ctx, `SELECT t.id FROM tasks t INNER JOIN status s ON t.status_id=s.id
+		WHERE t.user_id=$1
+		AND ($2 = '' OR lower(s.name)=lower($2) OR s.code_name=lower($2))
+		AND ($3 = '' OR EXISTS (SELECT 1 FROM task_tags tt INNER JOIN tags tg ON tg.id=tt.tag_id WHERE tt.task_id=t.id AND tg.name=lower($3)))
+		AND ($4::INT IS NULL OR t.project_id=$4)
+		ORDER BY t.id LIMIT $5 FOR UPDATE OF t;`, userID, filter.Status, filter.Tag, filter.ProjectID, MaxBulkItems+1)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err := rows.Scan(&id)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		ids = append(ids, id)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	return ids, nil
+}
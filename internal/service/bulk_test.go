@@ -0,0 +1,47 @@
+package service
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"testing"
+)
+
+func TestBulkRemoveMissingTag(t *testing.T) {
+	s := testService(t)
+	ctx := context.Background()
+	user := testUser(t, s, "bulk")
+	tagged := testTask(t, s, user.ID, "Tagged")
+	untagged := testTask(t, s, user.ID, "Untagged")
+
+	_, err := s.BulkTasks(ctx, &models.BulkRequest{Action: BulkAddTag, IDs: []int64{tagged.ID}, Tag: "errand"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var cursor int64
+	changesSince(t, s, user.ID, &cursor)
+
+	result, err := s.BulkTasks(ctx, &models.BulkRequest{Action: BulkRemoveTag, IDs: []int64{tagged.ID, untagged.ID}, Tag: "errand"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Succeeded != 1 || !result.Items[0].OK || result.Items[1].OK || result.Items[1].Error != ErrNotFound.Error() {
+		t.Errorf("removing the tag reported %+v", result.Items)
+	}
+	expectChanges(t, "removing the tag", changesSince(t, s, user.ID, &cursor), WebhookTaskUpdated, tagged.ID)
+
+	task, err := s.GetTaskByID(ctx, user.ID, untagged.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if task.Version != untagged.Version {
+		t.Errorf("the untagged task went from version %d to %d", untagged.Version, task.Version)
+	}
+
+	result, err = s.BulkTasks(ctx, &models.BulkRequest{Action: BulkRemoveTag, IDs: []int64{tagged.ID}, Tag: "unknown"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if result.Failed != 1 || len(changesSince(t, s, user.ID, &cursor)) != 0 {
+		t.Errorf("removing an unknown tag reported %+v", result.Items)
+	}
+}
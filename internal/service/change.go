@@ -0,0 +1,288 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// ChangeRetention is how long changes stay in the log for streams to resume from
+const ChangeRetention = 24 * time.Hour
+
+// changesChannel is the LISTEN/NOTIFY channel announcing new changes, the payload is the user id
+const changesChannel = "todo_changes"
+
+const changeColumns = "id, user_id, type, task_id, project_id, payload, created_at"
+
+// changeBroker wakes the streams of a user when one of their changes is committed
+type changeBroker struct {
+	mu   sync.Mutex
+	subs map[int64]map[chan struct{}]bool
+}
+
+func newChangeBroker() *changeBroker {
+	return &changeBroker{subs: make(map[int64]map[chan struct{}]bool)}
+}
+
+// notify signals the user's subscribers without ever blocking: a subscriber that has not picked up
+// the last signal yet will read everything new anyway
+func (b *changeBroker) notify(userID int64) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for ch := range b.subs[userID] {
+		select {
+		case ch <- struct{}{}:
+		default:
+		}
+	}
+}
+
+func (b *changeBroker) notifyAll() {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	for _, subs := range b.subs {
+		for ch := range subs {
+			select {
+			case ch <- struct{}{}:
+			default:
+			}
+		}
+	}
+}
+
+// SubscribeChanges returns a channel signalled whenever changes of the user are committed, and a function to unsubscribe
+func (s *Service) SubscribeChanges(userID int64) (<-chan struct{}, func()) {
+	ch := make(chan struct{}, 1)
+	b := s.changes
+	b.mu.Lock()
+	if b.subs[userID] == nil {
+		b.subs[userID] = make(map[chan struct{}]bool)
+	}
+	b.subs[userID][ch] = true
+	b.mu.Unlock()
+
+	return ch, func() {
+		b.mu.Lock()
+		delete(b.subs[userID], ch)
+		if len(b.subs[userID]) == 0 {
+			delete(b.subs, userID)
+		}
+		b.mu.Unlock()
+	}
+}
+
+// recordChange logs a change of the user's tasks or comments for streaming and queues it for their webhooks.
+// The user's changes are serialized until commit, so that their ids grow in commit order and a stream reading
+// past its last id never skips one
+func recordChange(ctx context.Context, q querier, userID int64, taskID int64, projectID *int64, event string, data interface{}) error {
+	payload, err := json.Marshal(data)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+
+	_, err = q.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('`+changesChannel+`'), $1);`, userID)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	_, err = q.Exec(ctx, `INSERT INTO changes (user_id, type, task_id, project_id, payload) VALUES ($1, $2, $3, $4, $5::JSONB);`, userID, event, taskID, projectID, string(payload))
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	_, err = q.Exec(ctx, `SELECT pg_notify('`+changesChannel+`', $1);`, strconv.FormatInt(userID, 10))
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+
+	return enqueueWebhooks(ctx, q, userID, projectID, event, data)
+}
+
+// recordTaskChange records the event with the task as it is now in q, checklist progress and reactions included,
+// for changes made around the task rather than to it, like its checklist, reactions or tags
+func recordTaskChange(ctx context.Context, q querier, userID int64, taskID int64, event string) error {
+	task := &models.Task{}
+	err := scanTask(q.QueryRow(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id=$1;`, taskID), task)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	err = loadChecklistProgress(ctx, q, task)
+	if err != nil {
+		return err
+	}
+	reactions, err := loadReactions(ctx, q, taskReactions, []int64{taskID}, userID)
+	if err != nil {
+		return err
+	}
+	task.Reactions = reactions[taskID]
+	return recordChange(ctx, q, userID, task.ID, task.ProjectID, event, task)
+}
+
+// recordTaskChanges is recordTaskChange for each of the tasks
+func recordTaskChanges(ctx context.Context, q querier, userID int64, taskIDs []int64, event string) error {
+	for _, taskID := range taskIDs {
+		err := recordTaskChange(ctx, q, userID, taskID, event)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// recordCommentChange records a comment change for the owner of the comment's task, scoped by the task's project
+func recordCommentChange(ctx context.Context, q querier, event string, comment *models.Comment) error {
+	var ownerID int64
+	var projectID *int64
+	err := q.QueryRow(ctx, `SELECT user_id, project_id FROM tasks WHERE id=$1;`, comment.TaskID).Scan(&ownerID, &projectID)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	return recordChange(ctx, q, ownerID, comment.TaskID, projectID, event, comment)
+}
+
+// ChangeCursor is where a stream starts reading: after lastID when resuming, otherwise after the user's latest change.
+// It reports a gap when changes after lastID may already have been pruned
+func (s *Service) ChangeCursor(ctx context.Context, userID int64, lastID *int64) (int64, bool, error) {
+	if lastID == nil {
+		var cursor int64
+		err := s.pool.QueryRow(ctx, `SELECT COALESCE(max(id), 0) FROM changes WHERE user_id=$1;`, userID).Scan(&cursor)
+		if err != nil {
+			lg.Error(err)
+			return 0, false, ErrInternal
+		}
+		return cursor, false, nil
+	}
+
+	var horizon int64
+	err := s.pool.QueryRow(ctx, `SELECT COALESCE((SELECT min(id) FROM changes), (SELECT CASE WHEN is_called THEN last_value + 1 ELSE last_value END FROM changes_id_seq));`).Scan(&horizon)
+	if err != nil {
+		lg.Error(err)
+		return 0, false, ErrInternal
+	}
+	return *lastID, *lastID+1 < horizon, nil
+}
+
+// CanWatch reports ErrNotFound unless the user may watch the task or project, kind is "task" or "project".
+// A task is watched by whoever may read it and its comments, so topics follow canSeeTask
+func (s *Service) CanWatch(ctx context.Context, userID int64, kind string, id int64) error {
+	if kind == "task" {
+		return s.canSeeTask(ctx, id, userID)
+	}
+
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM projects WHERE id=$1 and user_id=$2);`, id, userID).Scan(&exists)
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+	if !exists {
+		return ErrNotFound
+	}
+	return nil
+}
+
+// GetChangesAfter returns up to limit of the user's changes following the cursor, oldest first
+func (s *Service) GetChangesAfter(ctx context.Context, userID int64, cursor int64, limit int64) ([]*models.Change, error) {
+	items := make([]*models.Change, 0)
+	rows, err := s.pool.Query(ctx, `SELECT `+changeColumns+` FROM changes WHERE user_id=$1 and id > $2 ORDER BY id LIMIT $3;`, userID, cursor, limit)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.Change{}
+		err := rows.Scan(&item.ID, &item.UserID, &item.Type, &item.TaskID, &item.ProjectID, &item.Data, &item.CreatedAt)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// RunChangeListener relays change notifications from PostgreSQL to the subscribed streams until ctx is done.
+// Other instances' changes arrive the same way; after a lost connection every stream is woken to catch up
+func (s *Service) RunChangeListener(ctx context.Context) {
+	for ctx.Err() == nil {
+		err := s.listenChanges(ctx)
+		if err != nil && ctx.Err() == nil {
+			lg.Error(err)
+			s.changes.notifyAll()
+			select {
+			case <-ctx.Done():
+			case <-time.After(time.Second):
+			}
+		}
+	}
+}
+
+func (s *Service) listenChanges(ctx context.Context) error {
+	conn, err := s.pool.Acquire(ctx)
+	if err != nil {
+		return err
+	}
+	defer conn.Release()
+
+	_, err = conn.Exec(ctx, `LISTEN `+changesChannel+`;`)
+	if err != nil {
+		return err
+	}
+
+	for {
+		notification, err := conn.Conn().WaitForNotification(ctx)
+		if err != nil {
+			conn.Conn().Close(context.Background())
+			return err
+		}
+		userID, err := strconv.ParseInt(notification.Payload, 10, 64)
+		if err == nil {
+			s.changes.notify(userID)
+		}
+	}
+}
+
+// PruneChanges drops the changes older than ChangeRetention
+func (s *Service) PruneChanges(ctx context.Context) (int64, error) {
+	tag, err := s.pool.Exec(ctx, `DELETE FROM changes WHERE created_at < NOW() - $1 * INTERVAL '1 second';`, int64(ChangeRetention/time.Second))
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+
+	return tag.RowsAffected(), nil
+}
+
+// RunChangePruner prunes the change log every interval until ctx is done
+func (s *Service) RunChangePruner(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			pruned, err := s.PruneChanges(ctx)
+			if err == nil && pruned > 0 {
+				lg.Info("pruned changes: " + strconv.FormatInt(pruned, 10))
+			}
+		}
+	}
+}
@@ -0,0 +1,170 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"testing"
+	"time"
+)
+
+// changesSince returns the user's changes after cursor and moves the cursor past them
+func changesSince(t *testing.T, s *Service, userID int64, cursor *int64) []*models.Change {
+	t.Helper()
+	changes, err := s.GetChangesAfter(context.Background(), userID, *cursor, 1000)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(changes) > 0 {
+		*cursor = changes[len(changes)-1].ID
+	}
+	return changes
+}
+
+// expectChanges checks the type and task of every change in order
+func expectChanges(t *testing.T, what string, changes []*models.Change, event string, taskIDs ...int64) {
+	t.Helper()
+	if len(changes) != len(taskIDs) {
+		t.Errorf("%s recorded %d changes, want %d", what, len(changes), len(taskIDs))
+		return
+	}
+	for i, change := range changes {
+		if change.Type != event || change.TaskID != taskIDs[i] {
+			t.Errorf("%s recorded %s of task %d, want %s of task %d", what, change.Type, change.TaskID, event, taskIDs[i])
+		}
+	}
+}
+
+func TestMutationsRecordChanges(t *testing.T) {
+	s := testService(t)
+	ctx := context.Background()
+	user := testUser(t, s, "changes")
+	first := testTask(t, s, user.ID, "First")
+	second := testTask(t, s, user.ID, "Second")
+
+	var cursor int64
+	changesSince(t, s, user.ID, &cursor)
+
+	_, err := s.BulkTasks(ctx, &models.BulkRequest{Action: BulkAddTag, IDs: []int64{first.ID, second.ID}, Tag: "errand"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, "bulk add_tag", changesSince(t, s, user.ID, &cursor), WebhookTaskUpdated, first.ID, second.ID)
+
+	_, err = s.BulkTasks(ctx, &models.BulkRequest{Action: BulkComplete, IDs: []int64{first.ID, second.ID, 0}}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, "bulk complete", changesSince(t, s, user.ID, &cursor), WebhookTaskCompleted, first.ID, second.ID)
+
+	_, err = s.SnoozeTask(ctx, first.ID, time.Now().Add(time.Hour), user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.UnsnoozeTask(ctx, first.ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, "snooze and unsnooze", changesSince(t, s, user.ID, &cursor), WebhookTaskUpdated, first.ID, first.ID)
+
+	_, err = s.pool.Exec(ctx, `UPDATE tasks SET snoozed_until=NOW() - INTERVAL '1 minute' WHERE id=$1;`, second.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.WakeSnoozedTasks(ctx)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, "waking", changesSince(t, s, user.ID, &cursor), WebhookTaskUpdated, second.ID)
+
+	tags, _, err := s.GetAllTags(ctx, user.ID, &models.PageRequest{})
+	if err != nil || len(tags) != 1 {
+		t.Fatalf("tags = %v, %v", tags, err)
+	}
+	_, err = s.UpdateTag(ctx, &models.Tag{ID: tags[0].ID, Name: "chore", Color: tags[0].Color}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes := changesSince(t, s, user.ID, &cursor)
+	expectChanges(t, "renaming a tag", changes, WebhookTaskUpdated, first.ID, second.ID)
+	if len(changes) > 0 {
+		var task models.Task
+		json.Unmarshal(changes[0].Data, &task)
+		if len(task.Tags) != 1 || task.Tags[0] != "chore" {
+			t.Errorf("the change carries the tags %v, want the new name", task.Tags)
+		}
+	}
+	_, err = s.DeleteTagByID(ctx, tags[0].ID, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, "deleting a tag", changesSince(t, s, user.ID, &cursor), WebhookTaskUpdated, first.ID, second.ID)
+
+	item, err := s.AddChecklistItem(ctx, &models.ChecklistItem{TaskID: first.ID, Text: "milk"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.UpdateChecklistItem(ctx, &models.ChecklistItem{ID: item.ID, TaskID: first.ID, Text: "milk", Checked: true}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes = changesSince(t, s, user.ID, &cursor)
+	expectChanges(t, "checklist changes", changes, WebhookTaskUpdated, first.ID, first.ID)
+	if len(changes) == 2 {
+		var task models.Task
+		json.Unmarshal(changes[1].Data, &task)
+		if task.Checklist == nil || task.Checklist.Checked != 1 {
+			t.Errorf("the change carries the checklist %+v", task.Checklist)
+		}
+	}
+
+	_, _, err = s.ToggleTaskReaction(ctx, first.ID, user.ID, ":tada:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, "a task reaction", changesSince(t, s, user.ID, &cursor), WebhookTaskUpdated, first.ID)
+
+	comment, err := s.AddComment(ctx, &models.Comment{TaskID: first.ID, Content: "done"}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _, err = s.ToggleCommentReaction(ctx, comment.ID, user.ID, ":+1:")
+	if err != nil {
+		t.Fatal(err)
+	}
+	changes = changesSince(t, s, user.ID, &cursor)
+	if len(changes) != 2 || changes[1].Type != WebhookCommentUpdated {
+		t.Errorf("a comment reaction recorded %d changes", len(changes))
+	}
+
+	_, err = s.BulkTasks(ctx, &models.BulkRequest{Action: BulkDelete, IDs: []int64{first.ID, second.ID}}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	expectChanges(t, "bulk delete", changesSince(t, s, user.ID, &cursor), WebhookTaskDeleted, first.ID, second.ID)
+}
+
+func TestCanWatch(t *testing.T) {
+	s := testService(t)
+	ctx := context.Background()
+	owner := testUser(t, s, "watcher")
+	stranger := testUser(t, s, "passerby")
+	task := testTask(t, s, owner.ID, "Watched")
+
+	err := s.CanWatch(ctx, owner.ID, "task", task.ID)
+	if err != nil {
+		t.Errorf("a user who can read the task cannot watch it: %v", err)
+	}
+	if s.canSeeTask(ctx, task.ID, stranger.ID) == nil {
+		t.Fatal("the stranger can read the task")
+	}
+	err = s.CanWatch(ctx, stranger.ID, "task", task.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("a user who cannot read the task watches it: %v", err)
+	}
+	err = s.CanWatch(ctx, owner.ID, "project", task.ID+1000000)
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("a missing project: %v", err)
+	}
+}
@@ -0,0 +1,229 @@
+package service
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+)
+
+const checklistColumns = "c.id, c.task_id, c.text, c.checked, c.position, c.created_at"
+
+func scanChecklistItem(row pgx.Row, item *models.ChecklistItem) error {
+	return row.Scan(&item.ID, &item.TaskID, &item.Text, &item.Checked, &item.Position, &item.CreatedAt)
+}
+
+// addChecklistProgress fills the checklist summary of the tasks with a single query
+func (s *Service) addChecklistProgress(ctx context.Context, tasks ...*models.Task) error {
+	return loadChecklistProgress(ctx, s.pool, tasks...)
+}
+
+// loadChecklistProgress is addChecklistProgress reading through q, e.g. a transaction that just changed a checklist
+func loadChecklistProgress(ctx context.Context, q querier, tasks ...*models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Task, len(tasks))
+	ids := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		byID[task.ID] = task
+		ids = append(ids, task.ID)
+	}
+
+	rows, err := q.Query(ctx, `SELECT task_id, count(*), count(*) FILTER (WHERE checked) FROM checklist_items WHERE task_id = ANY($1) GROUP BY task_id;`, ids)
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID int64
+		progress := &models.ChecklistProgress{}
+		err := rows.Scan(&taskID, &progress.Total, &progress.Checked)
+		if err != nil {
+			lg.Error(err)
+			return ErrInternal
+		}
+		byID[taskID].Checklist = progress
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// GetChecklist method
+func (s *Service) GetChecklist(ctx context.Context, taskID int64, userID int64) ([]*models.ChecklistItem, error) {
+	_, err := s.GetTaskByID(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	items := make([]*models.ChecklistItem, 0)
+	rows, err := s.pool.Query(ctx, `SELECT `+checklistColumns+` FROM checklist_items c WHERE c.task_id=$1 ORDER BY c.position, c.id;`, taskID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.ChecklistItem{}
+		err := scanChecklistItem(rows, item)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// AddChecklistItem appends an item to the end of the task's checklist
+func (s *Service) AddChecklistItem(ctx context.Context, item *models.ChecklistItem, userID int64) (*models.ChecklistItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	checklistItem := &models.ChecklistItem{}
+	err = scanChecklistItem(tx.QueryRow(ctx, `INSERT INTO checklist_items AS c (task_id, text, checked, position)
+		SELECT id, $3, $4, COALESCE((SELECT MAX(position) FROM checklist_items WHERE task_id=$1), 0) + 1 FROM tasks WHERE id=$1 AND user_id=$2
+		RETURNING `+checklistColumns+`;`, item.TaskID, userID, item.Text, item.Checked), checklistItem)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	err = commitChecklistChange(ctx, tx, userID, checklistItem.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return checklistItem, nil
+}
+
+// UpdateChecklistItem method
+func (s *Service) UpdateChecklistItem(ctx context.Context, item *models.ChecklistItem, userID int64) (*models.ChecklistItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	checklistItem := &models.ChecklistItem{}
+	err = scanChecklistItem(tx.QueryRow(ctx, `UPDATE checklist_items c SET text=$1, checked=$2 FROM tasks t
+		WHERE c.id=$3 and c.task_id=$4 and t.id=c.task_id and t.user_id=$5 RETURNING `+checklistColumns+`;`, item.Text, item.Checked, item.ID, item.TaskID, userID), checklistItem)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	err = commitChecklistChange(ctx, tx, userID, checklistItem.TaskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return checklistItem, nil
+}
+
+// DeleteChecklistItem method
+func (s *Service) DeleteChecklistItem(ctx context.Context, taskID int64, id int64, userID int64) (*models.ChecklistItem, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	checklistItem := &models.ChecklistItem{}
+	err = scanChecklistItem(tx.QueryRow(ctx, `DELETE FROM checklist_items c USING tasks t
+		WHERE c.id=$1 and c.task_id=$2 and t.id=c.task_id and t.user_id=$3 RETURNING `+checklistColumns+`;`, id, taskID, userID), checklistItem)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	err = commitChecklistChange(ctx, tx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return checklistItem, nil
+}
+
+// commitChecklistChange records the task with its new checklist progress as updated and commits
+func commitChecklistChange(ctx context.Context, tx pgx.Tx, userID int64, taskID int64) error {
+	err := recordTaskChange(ctx, tx, userID, taskID, WebhookTaskUpdated)
+	if err != nil {
+		return ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+	return nil
+}
+
+// ReorderChecklist moves the items into the given order; every item of the checklist must be listed exactly once
+func (s *Service) ReorderChecklist(ctx context.Context, taskID int64, order *models.ChecklistOrder, userID int64) ([]*models.ChecklistItem, error) {
+	_, err := s.GetTaskByID(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	var count int64
+	var matching int64
+	err = tx.QueryRow(ctx, `SELECT count(*), count(*) FILTER (WHERE id = ANY($2)) FROM checklist_items WHERE task_id=$1;`, taskID, order.IDs).Scan(&count, &matching)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	seen := make(map[int64]bool, len(order.IDs))
+	for _, id := range order.IDs {
+		seen[id] = true
+	}
+	if count != matching || int64(len(order.IDs)) != count || len(seen) != len(order.IDs) {
+		return nil, ErrInvalidRequest
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE checklist_items c SET position=o.position FROM unnest($2::INT[]) WITH ORDINALITY AS o(id, position) WHERE c.id=o.id and c.task_id=$1;`, taskID, order.IDs)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	err = commitChecklistChange(ctx, tx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.GetChecklist(ctx, taskID, userID)
+}
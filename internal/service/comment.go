@@ -0,0 +1,262 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+)
+
+// MaxCommentDepth bounds how many levels of replies are nested into a listing
+const MaxCommentDepth = 10
+
+// commentAuthor selects the author's username next to commentColumns
+const commentAuthor = "(SELECT username FROM users WHERE users.id=comments.user_id)"
+
+// commentReplies counts the direct replies of a comment
+const commentReplies = "(SELECT count(*) FROM comments r WHERE r.parent_comment_id=comments.id)"
+
+// GetTaskComments lists a task's comments oldest first with their authors; only the task's owner may read them.
+// With depth 0 every comment is listed flat, otherwise the top level is paginated with depth levels of replies nested
+func (s *Service) GetTaskComments(ctx context.Context, taskID int64, userID int64, depth int, page *models.PageRequest) ([]*models.Comment, *models.PageInfo, error) {
+	err := s.canSeeTask(ctx, taskID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &filterSQL{}
+	where := "task_id=" + c.arg(taskID)
+	if depth > 0 {
+		where += " AND parent_comment_id IS NULL"
+	}
+	return s.listComments(ctx, userID, where, c, depth, page)
+}
+
+// GetCommentReplies lists the replies of a comment oldest first, with depth further levels nested. Replies are
+// readable by whoever can read the task's comments, not only by the comment's author
+func (s *Service) GetCommentReplies(ctx context.Context, id int64, userID int64, depth int, page *models.PageRequest) ([]*models.Comment, *models.PageInfo, error) {
+	var taskID int64
+	err := s.pool.QueryRow(ctx, `SELECT task_id FROM comments WHERE id=$1;`, id).Scan(&taskID)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, nil, ErrNotFound
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	err = s.canSeeTask(ctx, taskID, userID)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	c := &filterSQL{}
+	return s.listComments(ctx, userID, "parent_comment_id="+c.arg(id), c, depth, page)
+}
+
+// canSeeTask reports ErrNotFound unless the user may read the task and its comments, which only its owner may
+func (s *Service) canSeeTask(ctx context.Context, taskID int64, userID int64) error {
+	var visible bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM tasks WHERE id=$1 and user_id=$2);`, taskID, userID).Scan(&visible)
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+	if !visible {
+		return ErrNotFound
+	}
+	return nil
+}
+
+func (s *Service) listComments(ctx context.Context, userID int64, where string, c *filterSQL, depth int, page *models.PageRequest) ([]*models.Comment, *models.PageInfo, error) {
+	rows, info, err := s.listPage(ctx, &pageQuery{
+		columns: commentColumns + ", " + commentAuthor + ", " + commentReplies,
+		from:    "FROM comments",
+		where:   where,
+		c:       c,
+		keys:    []sortKey{{expr: "created_at", cast: "TIMESTAMP"}, {expr: "id", cast: "INT"}},
+	}, page, func() (interface{}, []interface{}) {
+		comment := &models.Comment{Author: &models.Author{}}
+		return comment, append(commentFields(comment), &comment.Author.Username, &comment.ReplyCount)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]*models.Comment, 0, len(rows))
+	for _, row := range rows {
+		comment := row.(*models.Comment)
+		comment.Author.ID = comment.UserID
+		items = append(items, comment)
+	}
+
+	if depth > 0 {
+		err = s.addReplies(ctx, items, depth)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	err = s.addCommentReactions(ctx, userID, items)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, info, nil
+}
+
+// addReplies nests up to depth levels of replies below the comments with a single recursive query
+func (s *Service) addReplies(ctx context.Context, comments []*models.Comment, depth int) error {
+	if len(comments) == 0 {
+		return nil
+	}
+	if depth > MaxCommentDepth {
+		depth = MaxCommentDepth
+	}
+
+	byID := make(map[int64]*models.Comment, len(comments))
+	ids := make([]int64, 0, len(comments))
+	for _, comment := range comments {
+		byID[comment.ID] = comment
+		ids = append(ids, comment.ID)
+	}
+
+	rows, err := s.pool.Query(ctx, `WITH RECURSIVE thread (id, level) AS (
+			SELECT id, 1 FROM comments WHERE parent_comment_id = ANY($1)
+			UNION ALL
+			SELECT r.id, thread.level+1 FROM comments r INNER JOIN thread ON r.parent_comment_id=thread.id WHERE thread.level < $2
+		)
+		SELECT `+commentColumns+`, `+commentAuthor+`, `+commentReplies+` FROM comments WHERE id IN (SELECT id FROM thread) ORDER BY created_at, id;`, ids, depth)
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+
+	defer rows.Close()
+
+	replies := make([]*models.Comment, 0)
+	for rows.Next() {
+		reply := &models.Comment{Author: &models.Author{}}
+		err := rows.Scan(append(commentFields(reply), &reply.Author.Username, &reply.ReplyCount)...)
+		if err != nil {
+			lg.Error(err)
+			return ErrInternal
+		}
+		reply.Author.ID = reply.UserID
+		byID[reply.ID] = reply
+		replies = append(replies, reply)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+
+	for _, reply := range replies {
+		parent := byID[*reply.ParentCommentID]
+		parent.Replies = append(parent.Replies, reply)
+	}
+	return nil
+}
+
+// GetCommentHistory returns the former contents of a comment oldest first; only the task's owner may read them
+func (s *Service) GetCommentHistory(ctx context.Context, id int64, userID int64) ([]*models.CommentEdit, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM comments c INNER JOIN tasks t ON t.id=c.task_id WHERE c.id=$1 and c.deleted_at IS NULL and t.user_id=$2);`, id, userID).Scan(&exists)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	if !exists {
+		return nil, ErrNotFound
+	}
+
+	items := make([]*models.CommentEdit, 0)
+	rows, err := s.pool.Query(ctx, `SELECT id, comment_id, content, version, edited_at, user_id FROM comment_edits WHERE comment_id=$1 ORDER BY id;`, id)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.CommentEdit{}
+		err := rows.Scan(&item.ID, &item.CommentID, &item.Content, &item.Version, &item.EditedAt, &item.UserID)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// pruneTombstones removes the deleted ancestors of a removed comment that no longer have any replies
+func pruneTombstones(ctx context.Context, tx querier, parentID *int64) error {
+	for parentID != nil {
+		tombstone := &models.Comment{}
+		err := scanComment(tx.QueryRow(ctx, `DELETE FROM comments WHERE id=$1 and deleted_at IS NOT NULL
+			and NOT EXISTS (SELECT 1 FROM comments r WHERE r.parent_comment_id=comments.id) RETURNING `+commentColumns+`;`, *parentID), tombstone)
+		if errors.Is(err, pgx.ErrNoRows) {
+			return nil
+		}
+		if err != nil {
+			lg.Error(err)
+			return err
+		}
+		err = recordCommentChange(ctx, tx, WebhookCommentDeleted, tombstone)
+		if err != nil {
+			return err
+		}
+		parentID = tombstone.ParentCommentID
+	}
+	return nil
+}
+
+// AddCommentCounts fills the comment count of the tasks with a single query, deleted comments are not counted
+func (s *Service) AddCommentCounts(ctx context.Context, tasks ...*models.Task) error {
+	if len(tasks) == 0 {
+		return nil
+	}
+
+	byID := make(map[int64]*models.Task, len(tasks))
+	ids := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		var zero int64
+		task.CommentCount = &zero
+		byID[task.ID] = task
+		ids = append(ids, task.ID)
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT task_id, count(*) FROM comments WHERE task_id = ANY($1) AND deleted_at IS NULL GROUP BY task_id;`, ids)
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var taskID, count int64
+		err := rows.Scan(&taskID, &count)
+		if err != nil {
+			lg.Error(err)
+			return ErrInternal
+		}
+		byID[taskID].CommentCount = &count
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+	return nil
+}
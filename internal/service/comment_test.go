@@ -0,0 +1,44 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"testing"
+)
+
+func TestGetCommentRepliesChecksTheTask(t *testing.T) {
+	s := testService(t)
+	ctx := context.Background()
+	owner := testUser(t, s, "owner")
+	author := testUser(t, s, "author")
+	task := testTask(t, s, owner.ID, "Discussed")
+
+	// a comment the task's owner did not write
+	var parentID int64
+	err := s.pool.QueryRow(ctx, `INSERT INTO comments (content, task_id, user_id) VALUES ('first', $1, $2) RETURNING id;`, task.ID, author.ID).Scan(&parentID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = s.AddComment(ctx, &models.Comment{TaskID: task.ID, Content: "reply", ParentCommentID: &parentID}, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	replies, _, err := s.GetCommentReplies(ctx, parentID, owner.ID, 1, &models.PageRequest{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(replies) != 1 || replies[0].Content != "reply" {
+		t.Errorf("the owner got the replies %+v", replies)
+	}
+
+	_, _, err = s.GetCommentReplies(ctx, parentID, author.ID, 1, &models.PageRequest{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("a user who cannot see the task got %v, want ErrNotFound", err)
+	}
+	_, _, err = s.GetCommentReplies(ctx, parentID+1000000, owner.ID, 1, &models.PageRequest{})
+	if !errors.Is(err, ErrNotFound) {
+		t.Errorf("a missing comment gave %v, want ErrNotFound", err)
+	}
+}
@@ -0,0 +1,66 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+)
+
+// maxNotifications is how many recent events GetNotifications returns
+const maxNotifications = 100
+
+const eventColumns = "id, user_id, type, task_id, payload, created_at"
+
+func scanEvent(row pgx.Row, event *models.Event) error {
+	var payload []byte
+	err := row.Scan(&event.ID, &event.UserID, &event.Type, &event.TaskID, &payload, &event.CreatedAt)
+	event.Payload = payload
+	return err
+}
+
+// emitEvent records an event for the user, inside the caller's transaction when q is one
+func emitEvent(ctx context.Context, q querier, userID int64, eventType string, taskID *int64, payload interface{}) (*models.Event, error) {
+	data, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+
+	event := &models.Event{}
+	err = scanEvent(q.QueryRow(ctx, `INSERT INTO events (user_id, type, task_id, payload) VALUES ($1, $2, $3, $4::JSONB) RETURNING `+eventColumns+`;`, userID, eventType, taskID, string(data)), event)
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+
+	return event, nil
+}
+
+// GetNotifications returns the user's most recent events, newest first
+func (s *Service) GetNotifications(ctx context.Context, userID int64) ([]*models.Event, error) {
+	items := make([]*models.Event, 0)
+	rows, err := s.pool.Query(ctx, `SELECT `+eventColumns+` FROM events WHERE user_id=$1 ORDER BY id DESC LIMIT $2;`, userID, maxNotifications)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.Event{}
+		err := scanEvent(rows, item)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return items, nil
+}
@@ -0,0 +1,132 @@
+package service
+
+import (
+	"github.com/AlifAcademy/TodoList/internal/filter"
+	"strconv"
+	"strings"
+)
+
+// filterColumns maps the date and number fields of the filter language to task columns
+var filterColumns = map[string]string{
+	filter.FieldProject:  "project_id",
+	filter.FieldPriority: "priority",
+	filter.FieldDue:      "due_at",
+	filter.FieldCreated:  "created_at",
+	filter.FieldUpdated:  "updated_at",
+}
+
+// filterSQL compiles a filter AST to a WHERE condition on the unaliased tasks table; values only ever travel as arguments
+type filterSQL struct {
+	args []interface{}
+}
+
+func (c *filterSQL) arg(value interface{}) string {
+	c.args = append(c.args, value)
+	return "$" + strconv.Itoa(len(c.args))
+}
+
+func (c *filterSQL) compile(node filter.Node) string {
+	switch n := node.(type) {
+	case nil:
+		return "TRUE"
+	case *filter.And:
+		return c.join(n.Nodes, " AND ")
+	case *filter.Or:
+		return c.join(n.Nodes, " OR ")
+	case *filter.Not:
+		return "NOT (" + c.compile(n.Node) + ")"
+	case *filter.Text:
+		return c.text(n.Value)
+	case *filter.Term:
+		return c.term(n)
+	}
+	return "FALSE"
+}
+
+func (c *filterSQL) join(nodes []filter.Node, sep string) string {
+	parts := make([]string, 0, len(nodes))
+	for _, node := range nodes {
+		parts = append(parts, "("+c.compile(node)+")")
+	}
+	return strings.Join(parts, sep)
+}
+
+// text matches the words as prefixes against the task's title, tags and description and its comments,
+// and fuzzily against the title and tag names so typos still find the task
+func (c *filterSQL) text(value string) string {
+	query := tsPrefixQuery(value)
+	if query == "" {
+		return "TRUE"
+	}
+	arg := c.arg(query)
+	fuzzy := c.arg(strings.TrimSpace(value))
+	return "(tasks.search_vector @@ to_tsquery(todo_search_language(), " + arg + ")" +
+		" OR EXISTS (SELECT 1 FROM comments cm WHERE cm.task_id=tasks.id AND cm.search_vector @@ to_tsquery(todo_search_language(), " + arg + "))" +
+		" OR " + fuzzy + " <% title" +
+		" OR EXISTS (SELECT 1 FROM task_tags tt INNER JOIN tags tg ON tg.id=tt.tag_id WHERE tt.task_id=tasks.id AND " + fuzzy + " <% tg.name))"
+}
+
+func (c *filterSQL) term(term *filter.Term) string {
+	switch term.Field {
+	case filter.FieldStatus:
+		names := make([]string, 0, len(term.Values)*3)
+		for _, value := range term.Values {
+			names = append(names, value.Text, strings.ReplaceAll(value.Text, " ", "_"), strings.NewReplacer(" ", "", "_", "").Replace(value.Text))
+		}
+		arg := c.arg(names)
+		return "status_id IN (SELECT id FROM status WHERE lower(name) = ANY(" + arg + ") OR code_name = ANY(" + arg + "))"
+	case filter.FieldTag:
+		names := make([]string, 0, len(term.Values))
+		for _, value := range term.Values {
+			names = append(names, normalizeTag(value.Text))
+		}
+		return "EXISTS (SELECT 1 FROM task_tags tt INNER JOIN tags tg ON tg.id=tt.tag_id WHERE tt.task_id=tasks.id AND tg.name = ANY(" + c.arg(names) + "))"
+	}
+
+	column := filterColumns[term.Field]
+	parts := make([]string, 0, len(term.Values))
+	for _, value := range term.Values {
+		parts = append(parts, c.compare(term.Field, column, term.Op, value))
+	}
+	if len(parts) == 1 {
+		return parts[0]
+	}
+	return "(" + strings.Join(parts, " OR ") + ")"
+}
+
+func (c *filterSQL) compare(field string, column string, op string, value filter.Value) string {
+	if value.None {
+		return column + " IS NULL"
+	}
+
+	switch field {
+	case filter.FieldProject, filter.FieldPriority:
+		sqlOp := op
+		if op == filter.OpEq {
+			sqlOp = "="
+		}
+		return column + " " + sqlOp + " " + c.arg(value.Int)
+	}
+
+	instant := value.From.Equal(value.To)
+	switch op {
+	case filter.OpLt:
+		return column + " < " + c.arg(value.From)
+	case filter.OpLe:
+		if instant {
+			return column + " <= " + c.arg(value.To)
+		}
+		return column + " < " + c.arg(value.To)
+	case filter.OpGt:
+		if instant {
+			return column + " > " + c.arg(value.To)
+		}
+		return column + " >= " + c.arg(value.To)
+	case filter.OpGe:
+		return column + " >= " + c.arg(value.From)
+	}
+	if instant {
+		return column + " = " + c.arg(value.From)
+	}
+	return "(" + column + " >= " + c.arg(value.From) + " AND " + column + " < " + c.arg(value.To) + ")"
+}
@@ -0,0 +1,111 @@
+package service
+
+import (
+	"github.com/AlifAcademy/TodoList/internal/filter"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+var testNow = time.Date(2026, 10, 19, 15, 30, 0, 0, time.UTC)
+
+func compileFilter(t *testing.T, c *filterSQL, input string) string {
+	t.Helper()
+	node, err := filter.Parse(input, testNow)
+	if err != nil {
+		t.Fatalf("Parse(%q): %v", input, err)
+	}
+	return c.compile(node)
+}
+
+func TestFilterCompile(t *testing.T) {
+	today := time.Date(2026, 10, 19, 0, 0, 0, 0, time.UTC)
+	tomorrow := today.AddDate(0, 0, 1)
+	tag := func(arg string) string {
+		return "EXISTS (SELECT 1 FROM task_tags tt INNER JOIN tags tg ON tg.id=tt.tag_id WHERE tt.task_id=tasks.id AND tg.name = ANY(" + arg + "))"
+	}
+
+	tests := []struct {
+		input string
+		sql   string
+		args  []interface{}
+	}{
+		{``, `TRUE`, nil},
+		{`priority:high`, `priority = $1`, []interface{}{int64(3)}},
+		{`priority>=2`, `priority >= $1`, []interface{}{int64(2)}},
+		{`priority<urgent`, `priority < $1`, []interface{}{int64(4)}},
+		{`project:7`, `project_id = $1`, []interface{}{int64(7)}},
+		{`project:none`, `project_id IS NULL`, nil},
+		{`project:1,2`, `(project_id = $1 OR project_id = $2)`, []interface{}{int64(1), int64(2)}},
+		{`project:1,none`, `(project_id = $1 OR project_id IS NULL)`, []interface{}{int64(1)}},
+		{`due:today`, `(due_at >= $1 AND due_at < $2)`, []interface{}{today, tomorrow}},
+		{`due<today`, `due_at < $1`, []interface{}{today}},
+		{`due<=today`, `due_at < $1`, []interface{}{tomorrow}},
+		{`due>today`, `due_at >= $1`, []interface{}{tomorrow}},
+		{`due>=today`, `due_at >= $1`, []interface{}{today}},
+		{`updated:now`, `updated_at = $1`, []interface{}{testNow}},
+		{`updated<=now`, `updated_at <= $1`, []interface{}{testNow}},
+		{`created>now`, `created_at > $1`, []interface{}{testNow}},
+		{`due:none`, `due_at IS NULL`, nil},
+		{`tag:Work,home`, tag("$1"), []interface{}{[]string{"work", "home"}}},
+		{`status:"in progress"`, `status_id IN (SELECT id FROM status WHERE lower(name) = ANY($1) OR code_name = ANY($1))`,
+			[]interface{}{[]string{"in progress", "in_progress", "inprogress"}}},
+		{`-tag:blocked`, `NOT (` + tag("$1") + `)`, []interface{}{[]string{"blocked"}}},
+		{`tag:a tag:b OR priority:1`, `((` + tag("$1") + `) AND (` + tag("$2") + `)) OR (priority = $3)`,
+			[]interface{}{[]string{"a"}, []string{"b"}, int64(1)}},
+		{`NOT (project:1 OR project:2) due:none`, `(NOT ((project_id = $1) OR (project_id = $2))) AND (due_at IS NULL)`,
+			[]interface{}{int64(1), int64(2)}},
+	}
+
+	for _, test := range tests {
+		c := &filterSQL{}
+		sql := compileFilter(t, c, test.input)
+		if sql != test.sql {
+			t.Errorf("compile(%q) =\n\t%s\nwant\n\t%s", test.input, sql, test.sql)
+		}
+		if len(c.args) != len(test.args) || (len(test.args) > 0 && !reflect.DeepEqual(c.args, test.args)) {
+			t.Errorf("compile(%q) args = %v, want %v", test.input, c.args, test.args)
+		}
+	}
+}
+
+func TestFilterCompileText(t *testing.T) {
+	c := &filterSQL{}
+	sql := compileFilter(t, c, `"quarterly re-port"`)
+
+	want := []interface{}{"quarterly:* & re:* & port:*", "quarterly re-port"}
+	if !reflect.DeepEqual(c.args, want) {
+		t.Fatalf("args = %q, want %q", c.args, want)
+	}
+	for _, part := range []string{
+		"tasks.search_vector @@ to_tsquery(todo_search_language(), $1)",
+		"cm.search_vector @@ to_tsquery(todo_search_language(), $1)",
+		"$2 <% title",
+		"$2 <% tg.name",
+	} {
+		if !strings.Contains(sql, part) {
+			t.Errorf("text condition %s does not contain %s", sql, part)
+		}
+	}
+
+	c = &filterSQL{}
+	if sql := compileFilter(t, c, `"--"`); sql != "TRUE" || len(c.args) != 0 {
+		t.Errorf("text without words = %s, %v", sql, c.args)
+	}
+}
+
+// TestFilterCompileNumbering checks that arguments continue after the ones the listing added before the filter
+func TestFilterCompileNumbering(t *testing.T) {
+	c := &filterSQL{}
+	where := "user_id=" + c.arg(int64(42))
+	where += " and (" + compileFilter(t, c, `priority:1 OR project:none OR project:5`) + ")"
+
+	want := "user_id=$1 and ((priority = $2) OR (project_id IS NULL) OR (project_id = $3))"
+	if where != want {
+		t.Fatalf("where =\n\t%s\nwant\n\t%s", where, want)
+	}
+	if !reflect.DeepEqual(c.args, []interface{}{int64(42), int64(1), int64(5)}) {
+		t.Fatalf("args = %v", c.args)
+	}
+}
@@ -0,0 +1,113 @@
+package service
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"regexp"
+	"strings"
+)
+
+// maxMentions bounds how many users one comment can notify
+const maxMentions = 20
+
+// mentionExcerpt is how many characters of the comment a mention notification carries
+const mentionExcerpt = 140
+
+// mentionPattern finds @username not preceded by a word character, so e-mail addresses are not mentions
+var mentionPattern = regexp.MustCompile(`(?:^|[^\w@.])@(\w[\w.-]*)`)
+
+// parseMentions returns the distinct lowercased usernames mentioned in content
+func parseMentions(content string) []string {
+	names := make([]string, 0)
+	seen := make(map[string]bool)
+	for _, match := range mentionPattern.FindAllStringSubmatch(content, -1) {
+		name := strings.ToLower(strings.TrimRight(match[1], ".-"))
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		names = append(names, name)
+		if len(names) == maxMentions {
+			break
+		}
+	}
+	return names
+}
+
+// syncMentions stores the mentions of a new or edited comment and notifies the users mentioned for the first time.
+// Only users who can see the task are mentioned, which is its owner until tasks can be shared
+func syncMentions(ctx context.Context, q querier, comment *models.Comment) error {
+	names := parseMentions(comment.Content)
+
+	added := make([]int64, 0)
+	rows, err := q.Query(ctx, `WITH mentioned AS (
+			SELECT u.id FROM users u INNER JOIN tasks t ON t.id=$2 AND t.user_id=u.id WHERE lower(u.username) = ANY($3)
+		), removed AS (
+			DELETE FROM comment_mentions WHERE comment_id=$1 AND user_id NOT IN (SELECT id FROM mentioned)
+		)
+		INSERT INTO comment_mentions (comment_id, user_id) SELECT $1, id FROM mentioned ON CONFLICT DO NOTHING RETURNING user_id;`, comment.ID, comment.TaskID, names)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	for rows.Next() {
+		var userID int64
+		err = rows.Scan(&userID)
+		if err != nil {
+			rows.Close()
+			lg.Error(err)
+			return err
+		}
+		added = append(added, userID)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+
+	excerpt := []rune(comment.Content)
+	if len(excerpt) > mentionExcerpt {
+		excerpt = excerpt[:mentionExcerpt]
+	}
+	for _, userID := range added {
+		if userID == comment.UserID {
+			continue
+		}
+		_, err = emitEvent(ctx, q, userID, models.EventCommentMention, &comment.TaskID, map[string]interface{}{
+			"comment_id": comment.ID,
+			"author_id":  comment.UserID,
+			"excerpt":    string(excerpt),
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	comment.Mentions = make([]*models.Author, 0)
+	rows, err = q.Query(ctx, `SELECT u.id, u.username FROM comment_mentions m INNER JOIN users u ON u.id=m.user_id WHERE m.comment_id=$1 ORDER BY u.username;`, comment.ID)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		author := &models.Author{}
+		err = rows.Scan(&author.ID, &author.Username)
+		if err != nil {
+			lg.Error(err)
+			return err
+		}
+		comment.Mentions = append(comment.Mentions, author)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	return nil
+}
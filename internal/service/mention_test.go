@@ -0,0 +1,106 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"reflect"
+	"testing"
+)
+
+func TestParseMentions(t *testing.T) {
+	tests := []struct {
+		content string
+		want    []string
+	}{
+		{"no mentions", []string{}},
+		{"@Kakashi can you check this", []string{"kakashi"}},
+		{"cc @a, @b and @A again", []string{"a", "b"}},
+		{"ask @naruto.", []string{"naruto"}},
+		{"mail me at me@example.com", []string{}},
+		{"(@sasuke) @@double", []string{"sasuke"}},
+		{"@first.last-name: hi", []string{"first.last-name"}},
+	}
+
+	for _, test := range tests {
+		if got := parseMentions(test.content); !reflect.DeepEqual(got, test.want) {
+			t.Errorf("parseMentions(%q) = %q, want %q", test.content, got, test.want)
+		}
+	}
+}
+
+// mentionEvents returns the comment ids of the user's mention notifications
+func mentionEvents(t *testing.T, s *Service, userID int64) []int64 {
+	t.Helper()
+	events, err := s.GetNotifications(context.Background(), userID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	ids := make([]int64, 0)
+	for _, event := range events {
+		if event.Type != models.EventCommentMention {
+			continue
+		}
+		var payload struct {
+			CommentID int64 `json:"comment_id"`
+		}
+		err = json.Unmarshal(event.Payload, &payload)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids = append(ids, payload.CommentID)
+	}
+	return ids
+}
+
+func TestMentionsOnlyUsersWhoSeeTheTask(t *testing.T) {
+	s := testService(t)
+	ctx := context.Background()
+	owner := testUser(t, s, "owner")
+	author := testUser(t, s, "author")
+	stranger := testUser(t, s, "stranger")
+	task := testTask(t, s, owner.ID, "Plan the trip")
+
+	comment, err := s.AddComment(ctx, &models.Comment{TaskID: task.ID, Content: "@" + stranger.Username + " @" + owner.Username + " what do you think?"}, owner.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(comment.Mentions) != 1 || comment.Mentions[0].ID != owner.ID {
+		t.Errorf("mentions = %+v, want only the owner", comment.Mentions)
+	}
+	if got := mentionEvents(t, s, stranger.ID); len(got) != 0 {
+		t.Errorf("a user who cannot see the task got mention events %v", got)
+	}
+	if got := mentionEvents(t, s, owner.ID); len(got) != 0 {
+		t.Errorf("mentioning yourself sent mention events %v", got)
+	}
+
+	_, err = s.AddComment(ctx, &models.Comment{TaskID: task.ID, Content: "let me in"}, stranger.ID)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("commenting on someone else's task: %v", err)
+	}
+
+	// a comment the owner did not write, edited to mention the owner
+	other := &models.Comment{}
+	err = scanComment(s.pool.QueryRow(ctx, `INSERT INTO comments (content, task_id, user_id) VALUES ('hello', $1, $2) RETURNING `+commentColumns+`;`, task.ID, author.ID), other)
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Content = "hello @" + owner.Username
+	edited, err := s.UpdateComment(ctx, other, author.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mentionEvents(t, s, owner.ID); !reflect.DeepEqual(got, []int64{edited.ID}) {
+		t.Errorf("the owner got mention events for comments %v, want [%d]", got, edited.ID)
+	}
+
+	_, err = s.UpdateComment(ctx, &models.Comment{ID: edited.ID, Content: "hello again @" + owner.Username}, author.ID, 0)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got := mentionEvents(t, s, owner.ID); len(got) != 1 {
+		t.Errorf("an edit keeping the mention notified again: %v", got)
+	}
+}
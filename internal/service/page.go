@@ -0,0 +1,217 @@
+package service
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgconn"
+	"hash/crc32"
+	"strconv"
+	"strings"
+)
+
+// DefaultPerPage and MaxPerPage bound the size of a listing page
+const (
+	DefaultPerPage = 50
+	MaxPerPage     = 200
+)
+
+// sortKey is one ORDER BY term of a listing. expr must not be NULL and cast must turn its text form back into its type;
+// the last key of a listing has to be unique so that cursors are stable
+type sortKey struct {
+	expr string
+	cast string
+	desc bool
+}
+
+// pageQuery describes a listing: columns are selected from from, rows filtered by where whose arguments are already in c
+type pageQuery struct {
+	columns string
+	from    string
+	where   string
+	c       *filterSQL
+	keys    []sortKey
+}
+
+// cursor is the opaque position handed to clients: the sort values of a row and a checksum of the ordering they belong to
+type cursor struct {
+	Sort   uint32   `json:"s"`
+	Values []string `json:"v"`
+}
+
+func sortSignature(keys []sortKey) uint32 {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		parts = append(parts, key.expr+" "+strconv.FormatBool(key.desc))
+	}
+	return crc32.ChecksumIEEE([]byte(strings.Join(parts, ",")))
+}
+
+func encodeCursor(keys []sortKey, values []string) string {
+	data, _ := json.Marshal(&cursor{Sort: sortSignature(keys), Values: values})
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func decodeCursor(keys []sortKey, encoded string) ([]string, error) {
+	data, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return nil, ErrInvalidRequest
+	}
+	c := &cursor{}
+	err = json.Unmarshal(data, c)
+	if err != nil || c.Sort != sortSignature(keys) || len(c.Values) != len(keys) {
+		return nil, ErrInvalidRequest
+	}
+	return c.Values, nil
+}
+
+// isDataException reports a value PostgreSQL could not convert, e.g. a tampered cursor
+func isDataException(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && strings.HasPrefix(pgErr.Code, "22")
+}
+
+// keyset is the condition selecting the rows after (or before, when backward) the cursor values
+func keyset(c *filterSQL, keys []sortKey, values []string, backward bool) string {
+	alternatives := make([]string, 0, len(keys))
+	for i, key := range keys {
+		parts := make([]string, 0, i+1)
+		for j := 0; j < i; j++ {
+			parts = append(parts, keys[j].expr+" = "+c.arg(values[j])+"::"+keys[j].cast)
+		}
+		op := ">"
+		if key.desc != backward {
+			op = "<"
+		}
+		parts = append(parts, key.expr+" "+op+" "+c.arg(values[i])+"::"+key.cast)
+		alternatives = append(alternatives, "("+strings.Join(parts, " AND ")+")")
+	}
+	return "(" + strings.Join(alternatives, " OR ") + ")"
+}
+
+func orderBy(keys []sortKey, backward bool) string {
+	parts := make([]string, 0, len(keys))
+	for _, key := range keys {
+		if key.desc != backward {
+			parts = append(parts, key.expr+" DESC")
+		} else {
+			parts = append(parts, key.expr+" ASC")
+		}
+	}
+	return strings.Join(parts, ", ")
+}
+
+// listPage runs a listing for one page, selected by page number or by an after/before cursor.
+// newItem allocates a row and returns it with its scan destinations for q.columns.
+func (s *Service) listPage(ctx context.Context, q *pageQuery, page *models.PageRequest, newItem func() (interface{}, []interface{})) ([]interface{}, *models.PageInfo, error) {
+	info := &models.PageInfo{Page: page.Page, PerPage: page.PerPage}
+	if info.PerPage <= 0 {
+		info.PerPage = DefaultPerPage
+	}
+	if info.PerPage > MaxPerPage {
+		info.PerPage = MaxPerPage
+	}
+
+	err := s.pool.QueryRow(ctx, `SELECT count(*) `+q.from+` WHERE `+q.where+`;`, q.c.args...).Scan(&info.Total)
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	where := q.where
+	backward := page.Before != ""
+	position := page.After
+	if backward {
+		position = page.Before
+	}
+	if position != "" {
+		values, err := decodeCursor(q.keys, position)
+		if err != nil {
+			return nil, nil, err
+		}
+		where = "(" + where + ") AND " + keyset(q.c, q.keys, values, backward)
+		info.Page = 0
+	} else if info.Page <= 0 {
+		info.Page = 1
+	}
+
+	values := make([]string, 0, len(q.keys))
+	for _, key := range q.keys {
+		values = append(values, "("+key.expr+")::TEXT")
+	}
+	query := `SELECT ` + q.columns + `, ARRAY[` + strings.Join(values, ", ") + `] ` + q.from + ` WHERE ` + where +
+		` ORDER BY ` + orderBy(q.keys, backward) + ` LIMIT ` + q.c.arg(info.PerPage+1)
+	if info.Page > 1 {
+		query += ` OFFSET ` + q.c.arg((info.Page-1)*info.PerPage)
+	}
+
+	rows, err := s.pool.Query(ctx, query+`;`, q.c.args...)
+	if isDataException(err) {
+		return nil, nil, ErrInvalidRequest
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	items := make([]interface{}, 0, info.PerPage+1)
+	positions := make([][]string, 0, info.PerPage+1)
+	for rows.Next() {
+		item, fields := newItem()
+		var position []string
+		err := rows.Scan(append(fields, &position)...)
+		if err != nil {
+			lg.Error(err)
+			return nil, nil, ErrInternal
+		}
+		items = append(items, item)
+		positions = append(positions, position)
+	}
+
+	err = rows.Err()
+	if isDataException(err) {
+		return nil, nil, ErrInvalidRequest
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	more := int64(len(items)) > info.PerPage
+	if more {
+		items = items[:info.PerPage]
+		positions = positions[:info.PerPage]
+	}
+	if backward {
+		for i, j := 0, len(items)-1; i < j; i, j = i+1, j-1 {
+			items[i], items[j] = items[j], items[i]
+			positions[i], positions[j] = positions[j], positions[i]
+		}
+	}
+
+	if len(items) == 0 {
+		if backward {
+			info.Next = page.Before
+		}
+		return items, info, nil
+	}
+	first, last := positions[0], positions[len(positions)-1]
+	if backward {
+		info.Next = encodeCursor(q.keys, last)
+		if more {
+			info.Prev = encodeCursor(q.keys, first)
+		}
+	} else {
+		if more {
+			info.Next = encodeCursor(q.keys, last)
+		}
+		if page.After != "" || info.Page > 1 {
+			info.Prev = encodeCursor(q.keys, first)
+		}
+	}
+	return items, info, nil
+}
@@ -0,0 +1,106 @@
+package service
+
+import (
+	"encoding/base64"
+	"errors"
+	"reflect"
+	"strconv"
+	"testing"
+)
+
+var testKeys = []sortKey{
+	{expr: "priority", cast: "INT", desc: true},
+	{expr: "lower(title)", cast: "TEXT"},
+	{expr: "id", cast: "INT"},
+}
+
+func TestKeyset(t *testing.T) {
+	values := []string{"3", "report", "17"}
+
+	tests := []struct {
+		backward bool
+		want     string
+	}{
+		{false, "((priority < $2::INT) OR (priority = $3::INT AND lower(title) > $4::TEXT) OR " +
+			"(priority = $5::INT AND lower(title) = $6::TEXT AND id > $7::INT))"},
+		{true, "((priority > $2::INT) OR (priority = $3::INT AND lower(title) < $4::TEXT) OR " +
+			"(priority = $5::INT AND lower(title) = $6::TEXT AND id < $7::INT))"},
+	}
+
+	for _, test := range tests {
+		c := &filterSQL{}
+		c.arg(int64(42))
+		got := keyset(c, testKeys, values, test.backward)
+		if got != test.want {
+			t.Errorf("keyset(backward=%v) =\n\t%s\nwant\n\t%s", test.backward, got, test.want)
+		}
+		wantArgs := []interface{}{int64(42), "3", "3", "report", "3", "report", "17"}
+		if !reflect.DeepEqual(c.args, wantArgs) {
+			t.Errorf("keyset(backward=%v) args = %v, want %v", test.backward, c.args, wantArgs)
+		}
+	}
+}
+
+func TestOrderBy(t *testing.T) {
+	if got := orderBy(testKeys, false); got != "priority DESC, lower(title) ASC, id ASC" {
+		t.Errorf("orderBy forward = %s", got)
+	}
+	if got := orderBy(testKeys, true); got != "priority ASC, lower(title) DESC, id DESC" {
+		t.Errorf("orderBy backward = %s", got)
+	}
+}
+
+func TestCursor(t *testing.T) {
+	values := []string{"3", "quarterly, \"report\"", "17"}
+	encoded := encodeCursor(testKeys, values)
+
+	decoded, err := decodeCursor(testKeys, encoded)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(decoded, values) {
+		t.Fatalf("decoded %q, want %q", decoded, values)
+	}
+
+	otherOrder := []sortKey{testKeys[0], testKeys[1], {expr: "id", cast: "INT", desc: true}}
+	fewerKeys := testKeys[1:]
+	tampered := func(json string) string {
+		return base64.RawURLEncoding.EncodeToString([]byte(json))
+	}
+
+	signature := strconv.FormatUint(uint64(sortSignature(testKeys)), 10)
+	tests := []struct {
+		name    string
+		keys    []sortKey
+		encoded string
+	}{
+		{"another sort order", otherOrder, encoded},
+		{"fewer keys", fewerKeys, encoded},
+		{"signature mismatch", testKeys, tampered(`{"s":1,"v":["3","a","17"]}`)},
+		{"wrong number of values", testKeys, tampered(`{"s":` + signature + `,"v":["3","a"]}`)},
+		{"not base64", testKeys, "%%%"},
+		{"not json", testKeys, tampered("cursor")},
+		{"empty", testKeys, ""},
+	}
+	for _, test := range tests {
+		_, err := decodeCursor(test.keys, test.encoded)
+		if !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("%s: error = %v, want ErrInvalidRequest", test.name, err)
+		}
+	}
+
+	valid := tampered(`{"s":` + signature + `,"v":["3","a","17"]}`)
+	if _, err := decodeCursor(testKeys, valid); err != nil {
+		t.Errorf("a cursor with the right signature was rejected: %v", err)
+	}
+}
+
+func TestSortSignature(t *testing.T) {
+	flipped := []sortKey{testKeys[0], {expr: "lower(title)", cast: "TEXT", desc: true}, testKeys[2]}
+	if sortSignature(testKeys) == sortSignature(flipped) {
+		t.Error("the direction of a key does not change the signature")
+	}
+	if sortSignature(testKeys) != sortSignature(append([]sortKey(nil), testKeys...)) {
+		t.Error("the same keys give different signatures")
+	}
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/models"
+)
+
+// NewProject method
+func (s *Service) NewProject(ctx context.Context, item *models.Project, userID int64) (*models.Project, error) {
+	project := &models.Project{}
+	err := s.pool.QueryRow(ctx, `INSERT INTO projects (name, user_id) VALUES ($1, $2) RETURNING id, name, created_at, user_id;`, item.Name, userID).Scan(&project.ID, &project.Name, &project.CreatedAt, &project.UserID)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+
+	return project, nil
+}
+
+// GetAllProjects method
+func (s *Service) GetAllProjects(ctx context.Context, userID int64) ([]*models.Project, error) {
+	items := make([]*models.Project, 0)
+	rows, err := s.pool.Query(ctx, `SELECT id, name, created_at, user_id FROM projects WHERE user_id=$1 ORDER BY id;`, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.Project{}
+		err := rows.Scan(&item.ID, &item.Name, &item.CreatedAt, &item.UserID)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return items, nil
+}
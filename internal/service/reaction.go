@@ -0,0 +1,271 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+)
+
+// maxEmojiRunes allows for skin tones, flags and joined sequences like family emoji
+const maxEmojiRunes = 10
+
+// shortCode is the :name: form of an emoji
+var shortCode = regexp.MustCompile(`^:[a-z0-9_+-]{1,32}:$`)
+
+// shortCodes maps the common short codes to their emoji so that both spellings count as the same reaction
+var shortCodes = map[string]string{
+	":+1:":               "👍",
+	":thumbsup:":         "👍",
+	":-1:":               "👎",
+	":thumbsdown:":       "👎",
+	":heart:":            "❤️",
+	":smile:":            "😄",
+	":laughing:":         "😆",
+	":tada:":             "🎉",
+	":hooray:":           "🎉",
+	":confused:":         "😕",
+	":eyes:":             "👀",
+	":rocket:":           "🚀",
+	":fire:":             "🔥",
+	":clap:":             "👏",
+	":pray:":             "🙏",
+	":100:":              "💯",
+	":white_check_mark:": "✅",
+	":x:":                "❌",
+}
+
+// reactionTargets are the tables holding reactions and the column naming what was reacted to
+var (
+	taskReactions    = reactionTarget{table: "task_reactions", column: "task_id"}
+	commentReactions = reactionTarget{table: "comment_reactions", column: "comment_id"}
+)
+
+type reactionTarget struct {
+	table  string
+	column string
+}
+
+// normalizeEmoji accepts a single emoji, possibly a joined sequence, or a :short_code:
+func normalizeEmoji(value string) (string, error) {
+	value = strings.TrimSpace(value)
+	if shortCode.MatchString(strings.ToLower(value)) {
+		value = strings.ToLower(value)
+		if emoji, ok := shortCodes[value]; ok {
+			return emoji, nil
+		}
+		return value, nil
+	}
+
+	count := utf8.RuneCountInString(value)
+	if count == 0 || count > maxEmojiRunes {
+		return "", fmt.Errorf("%w: reaction must be an emoji or a :short_code:", ErrInvalidRequest)
+	}
+	keycap := strings.ContainsRune(value, 0x20E3)
+	pictographic := false
+	for _, r := range value {
+		switch {
+		case isPictographic(r):
+			pictographic = true
+		case r == 0x200D, r == 0xFE0E, r == 0xFE0F, r == 0x20E3, r >= 0x1F3FB && r <= 0x1F3FF, r >= 0xE0020 && r <= 0xE007F:
+		case keycap && (r == '#' || r == '*' || (r >= '0' && r <= '9')):
+			pictographic = true
+		default:
+			return "", fmt.Errorf("%w: reaction must be an emoji or a :short_code:", ErrInvalidRequest)
+		}
+	}
+	if !pictographic {
+		return "", fmt.Errorf("%w: reaction must be an emoji or a :short_code:", ErrInvalidRequest)
+	}
+	return value, nil
+}
+
+func isPictographic(r rune) bool {
+	switch {
+	case r >= 0x1F000 && r <= 0x1FAFF, r >= 0x2600 && r <= 0x27BF, r >= 0x2300 && r <= 0x23FF, r >= 0x2B00 && r <= 0x2BFF:
+		return true
+	case r >= 0x2190 && r <= 0x21FF, r >= 0x25A0 && r <= 0x25FF:
+		return true
+	case r == 0x00A9, r == 0x00AE, r == 0x203C, r == 0x2049, r == 0x2122, r == 0x2139, r == 0x3030, r == 0x303D, r == 0x3297, r == 0x3299:
+		return true
+	}
+	return false
+}
+
+// ToggleTaskReaction adds the user's reaction to a task, or takes it back if it was already there
+func (s *Service) ToggleTaskReaction(ctx context.Context, taskID int64, userID int64, emoji string) ([]*models.Reaction, bool, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM tasks WHERE id=$1 and user_id=$2);`, taskID, userID).Scan(&exists)
+	if err != nil {
+		lg.Error(err)
+		return nil, false, ErrInternal
+	}
+	if !exists {
+		return nil, false, ErrNotFound
+	}
+	return s.toggleReaction(ctx, taskReactions, taskID, userID, emoji)
+}
+
+// ToggleCommentReaction adds the user's reaction to a comment on one of their tasks, or takes it back
+func (s *Service) ToggleCommentReaction(ctx context.Context, commentID int64, userID int64, emoji string) ([]*models.Reaction, bool, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM comments c INNER JOIN tasks t ON t.id=c.task_id WHERE c.id=$1 and c.deleted_at IS NULL and t.user_id=$2);`, commentID, userID).Scan(&exists)
+	if err != nil {
+		lg.Error(err)
+		return nil, false, ErrInternal
+	}
+	if !exists {
+		return nil, false, ErrNotFound
+	}
+	return s.toggleReaction(ctx, commentReactions, commentID, userID, emoji)
+}
+
+// toggleReaction flips one reaction and returns the target's reactions afterwards along with whether it is now set
+func (s *Service) toggleReaction(ctx context.Context, target reactionTarget, id int64, userID int64, value string) ([]*models.Reaction, bool, error) {
+	emoji, err := normalizeEmoji(value)
+	if err != nil {
+		return nil, false, err
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, false, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	reacted := false
+	var deleted int64
+	err = tx.QueryRow(ctx, `DELETE FROM `+target.table+` WHERE `+target.column+`=$1 and user_id=$2 and emoji=$3 RETURNING `+target.column+`;`, id, userID, emoji).Scan(&deleted)
+	if errors.Is(err, pgx.ErrNoRows) {
+		reacted = true
+		_, err = tx.Exec(ctx, `INSERT INTO `+target.table+` (`+target.column+`, user_id, emoji) VALUES ($1, $2, $3) ON CONFLICT DO NOTHING;`, id, userID, emoji)
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, false, ErrInternal
+	}
+
+	switch target {
+	case taskReactions:
+		err = recordTaskChange(ctx, tx, userID, id, WebhookTaskUpdated)
+	case commentReactions:
+		err = recordCommentReactions(ctx, tx, userID, id)
+	}
+	if err != nil {
+		return nil, false, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, false, ErrInternal
+	}
+
+	reactions, err := loadReactions(ctx, s.pool, target, []int64{id}, userID)
+	if err != nil {
+		return nil, false, err
+	}
+	items := reactions[id]
+	if items == nil {
+		items = make([]*models.Reaction, 0)
+	}
+	return items, reacted, nil
+}
+
+// recordCommentReactions records the comment with its new reactions as updated
+func recordCommentReactions(ctx context.Context, q querier, userID int64, commentID int64) error {
+	comment := &models.Comment{}
+	err := scanComment(q.QueryRow(ctx, `SELECT `+commentColumns+` FROM comments WHERE id=$1;`, commentID), comment)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	reactions, err := loadReactions(ctx, q, commentReactions, []int64{commentID}, userID)
+	if err != nil {
+		return err
+	}
+	comment.Reactions = reactions[commentID]
+	return recordCommentChange(ctx, q, WebhookCommentUpdated, comment)
+}
+
+// loadReactions counts the reactions of the given ids per emoji in one grouped query, in the order they were first used
+func loadReactions(ctx context.Context, q querier, target reactionTarget, ids []int64, userID int64) (map[int64][]*models.Reaction, error) {
+	byID := make(map[int64][]*models.Reaction, len(ids))
+	if len(ids) == 0 {
+		return byID, nil
+	}
+
+	rows, err := q.Query(ctx, `SELECT `+target.column+`, emoji, count(*), bool_or(user_id=$2) FROM `+target.table+`
+		WHERE `+target.column+` = ANY($1) GROUP BY `+target.column+`, emoji ORDER BY min(created_at), emoji;`, ids, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		var id int64
+		reaction := &models.Reaction{}
+		err := rows.Scan(&id, &reaction.Emoji, &reaction.Count, &reaction.ReactedByMe)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		byID[id] = append(byID[id], reaction)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	return byID, nil
+}
+
+// addTaskReactions fills the reactions of the tasks as seen by the user
+func (s *Service) addTaskReactions(ctx context.Context, userID int64, tasks ...*models.Task) error {
+	ids := make([]int64, 0, len(tasks))
+	for _, task := range tasks {
+		ids = append(ids, task.ID)
+	}
+	reactions, err := loadReactions(ctx, s.pool, taskReactions, ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, task := range tasks {
+		task.Reactions = reactions[task.ID]
+	}
+	return nil
+}
+
+// addCommentReactions fills the reactions of the comments and their nested replies as seen by the user
+func (s *Service) addCommentReactions(ctx context.Context, userID int64, comments []*models.Comment) error {
+	all := make([]*models.Comment, 0, len(comments))
+	var walk func([]*models.Comment)
+	walk = func(comments []*models.Comment) {
+		for _, comment := range comments {
+			all = append(all, comment)
+			walk(comment.Replies)
+		}
+	}
+	walk(comments)
+
+	ids := make([]int64, 0, len(all))
+	for _, comment := range all {
+		ids = append(ids, comment.ID)
+	}
+	reactions, err := loadReactions(ctx, s.pool, commentReactions, ids, userID)
+	if err != nil {
+		return err
+	}
+	for _, comment := range all {
+		comment.Reactions = reactions[comment.ID]
+	}
+	return nil
+}
@@ -0,0 +1,214 @@
+package service
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/filter"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"html"
+	"strings"
+	"unicode"
+)
+
+// MaxSearchResults is the largest number of results a search returns
+const MaxSearchResults = 100
+
+// ts_headline wraps matches in these markers; the snippet is escaped before they become <mark> tags
+const (
+	markStart = "\x02"
+	markStop  = "\x03"
+)
+
+const headlineOptions = "StartSel=" + markStart + ", StopSel=" + markStop + `, MaxWords=25, MinWords=10, MaxFragments=2, FragmentDelimiter=" … "`
+
+var markReplacer = strings.NewReplacer(markStart, "<mark>", markStop, "</mark>")
+
+// tsPrefixQuery turns free text into a to_tsquery expression matching every word as a prefix.
+// Anything but letters and digits is dropped, so the text cannot carry tsquery syntax.
+func tsPrefixQuery(text string) string {
+	words := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	for i, word := range words {
+		words[i] = word + ":*"
+	}
+	return strings.Join(words, " & ")
+}
+
+func highlight(snippet string) string {
+	if !strings.Contains(snippet, markStart) {
+		return ""
+	}
+	return markReplacer.Replace(html.EscapeString(snippet))
+}
+
+// Search ranks the user's tasks whose title, tags, description or comments match the text, optionally narrowed by a filter
+func (s *Service) Search(ctx context.Context, userID int64, text string, expr filter.Node, limit int64) ([]*models.SearchResult, error) {
+	tsquery := tsPrefixQuery(text)
+	if tsquery == "" {
+		return nil, ErrInvalidRequest
+	}
+	if limit <= 0 || limit > MaxSearchResults {
+		limit = MaxSearchResults
+	}
+
+	c := &filterSQL{}
+	user := c.arg(userID)
+	query := c.arg(tsquery)
+	options := c.arg(headlineOptions)
+	titleOptions := c.arg(headlineOptions + ", HighlightAll=true")
+	where := "TRUE"
+	if expr != nil {
+		where = c.compile(expr)
+	}
+
+	rows, err := s.pool.Query(ctx, `WITH q AS (SELECT to_tsquery(todo_search_language(), `+query+`) AS query),
+		hits AS (
+			SELECT t.id AS hit_id, ts_rank(t.search_vector, q.query) AS hit_rank FROM tasks t, q
+			WHERE t.user_id=`+user+` AND t.search_vector @@ q.query
+			UNION ALL
+			SELECT cm.task_id, ts_rank(cm.search_vector, q.query) * 0.5 FROM comments cm INNER JOIN tasks t ON t.id=cm.task_id, q
+			WHERE t.user_id=`+user+` AND cm.search_vector @@ q.query
+		),
+		ranked AS (SELECT hit_id, max(hit_rank) AS search_rank FROM hits GROUP BY hit_id)
+		SELECT `+taskColumns+`, ranked.search_rank::FLOAT8,
+			ts_headline(todo_search_language(), title, q.query, `+titleOptions+`),
+			ts_headline(todo_search_language(), COALESCE(description, ''), q.query, `+options+`),
+			COALESCE((SELECT ts_headline(todo_search_language(), cm.content, q.query, `+options+`) FROM comments cm
+				WHERE cm.task_id=tasks.id AND cm.search_vector @@ q.query ORDER BY ts_rank(cm.search_vector, q.query) DESC LIMIT 1), '')
+		FROM ranked INNER JOIN tasks ON tasks.id=ranked.hit_id, q
+		WHERE (`+where+`)
+		ORDER BY ranked.search_rank DESC, tasks.id DESC
+		LIMIT `+c.arg(limit)+`;`, c.args...)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	items := make([]*models.SearchResult, 0)
+	for rows.Next() {
+		item := &models.SearchResult{Task: &models.Task{}, Highlights: &models.SearchHighlight{}}
+		err := rows.Scan(append(taskFields(item.Task), &item.Rank, &item.Highlights.Title, &item.Highlights.Description, &item.Highlights.Comment)...)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		item.Highlights.Title = highlight(item.Highlights.Title)
+		item.Highlights.Description = highlight(item.Highlights.Description)
+		item.Highlights.Comment = highlight(item.Highlights.Comment)
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// MaxCompletions is the largest number of autocomplete entries returned
+const MaxCompletions = 20
+
+// SuggestQuery proposes a corrected query by replacing each unknown word with the closest word from
+// the user's task titles and tag names; it returns "" when there is nothing better to suggest
+func (s *Service) SuggestQuery(ctx context.Context, userID int64, text string) (string, error) {
+	words := strings.FieldsFunc(strings.ToLower(text), func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r)
+	})
+	if len(words) == 0 {
+		return "", nil
+	}
+
+	rows, err := s.pool.Query(ctx, `WITH vocabulary AS (
+			SELECT DISTINCT word FROM tasks, regexp_split_to_table(lower(title), '[^[:alnum:]]+') word WHERE user_id=$1 AND length(word) > 2
+			UNION
+			SELECT name FROM tags WHERE user_id=$1
+		)
+		SELECT COALESCE(best.word, q.word)
+		FROM unnest($2::TEXT[]) WITH ORDINALITY AS q(word, n)
+		LEFT JOIN LATERAL (
+			SELECT v.word FROM vocabulary v
+			WHERE similarity(v.word, q.word) >= 0.3 AND NOT EXISTS (SELECT 1 FROM vocabulary known WHERE known.word=q.word)
+			ORDER BY similarity(v.word, q.word) DESC, v.word LIMIT 1
+		) best ON TRUE
+		ORDER BY q.n;`, userID, words)
+	if err != nil {
+		lg.Error(err)
+		return "", ErrInternal
+	}
+
+	defer rows.Close()
+
+	suggested := make([]string, 0, len(words))
+	for rows.Next() {
+		var word string
+		err := rows.Scan(&word)
+		if err != nil {
+			lg.Error(err)
+			return "", ErrInternal
+		}
+		suggested = append(suggested, word)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return "", ErrInternal
+	}
+
+	suggestion := strings.Join(suggested, " ")
+	if suggestion == strings.Join(words, " ") {
+		return "", nil
+	}
+	return suggestion, nil
+}
+
+// Autocomplete returns the tasks and tags whose title or name starts with or closely resembles the text,
+// best matches first; both lookups are served by the trigram indexes
+func (s *Service) Autocomplete(ctx context.Context, userID int64, text string, limit int64) ([]*models.Completion, error) {
+	text = strings.TrimSpace(text)
+	if text == "" {
+		return nil, ErrInvalidRequest
+	}
+	if limit <= 0 || limit > MaxCompletions {
+		limit = MaxCompletions
+	}
+	prefix := likeEscaper.Replace(text) + "%"
+
+	rows, err := s.pool.Query(ctx, `SELECT kind, id, label, score FROM (
+			(SELECT 'task' AS kind, id, title AS label, ((title ILIKE $3)::INT + word_similarity($2, title))::FLOAT8 AS score FROM tasks
+			WHERE user_id=$1 AND (title ILIKE $3 OR $2 <% title) ORDER BY score DESC, id DESC LIMIT $4)
+			UNION ALL
+			(SELECT 'tag', id, name, ((name ILIKE $3)::INT + word_similarity($2, name))::FLOAT8 FROM tags
+			WHERE user_id=$1 AND (name ILIKE $3 OR $2 <% name) ORDER BY 4 DESC, id DESC LIMIT $4)
+		) completions
+		ORDER BY score DESC, kind, id DESC LIMIT $4;`, userID, text, prefix, limit)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	items := make([]*models.Completion, 0)
+	for rows.Next() {
+		item := &models.Completion{}
+		err := rows.Scan(&item.Type, &item.ID, &item.Label, &item.Score)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
@@ -2,15 +2,18 @@ package service
 
 import (
 	"context"
-	"database/sql"
 	"errors"
-	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/filter"
 	"github.com/AlifAcademy/TodoList/internal/logger"
 	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/AlifAcademy/TodoList/internal/storage"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
 	"github.com/jackc/pgx/v4/pgxpool"
 	"golang.org/x/crypto/bcrypt"
 	"log"
-	"strings"
+	"net/http"
+	"time"
 )
 
 // ErrNotFound if an item not found
@@ -25,16 +28,60 @@ var ErrNoSuchUser = errors.New("no such user")
 // ErrInvalidPassword if password is incorrect
 var ErrInvalidPassword = errors.New("invalid password")
 
+// ErrInvalidRequest if the request can not be carried out as given
+var ErrInvalidRequest = errors.New("invalid request")
+
+// ErrPreconditionFailed if the item was changed since the version the client has seen
+var ErrPreconditionFailed = errors.New("precondition failed")
+
+// taskColumns is the column list every task query selects, in scanTask order.
+// Tags live in task_tags, so queries using it must not alias the tasks table.
+const taskColumns = "id, title, description, " + taskTags + ", status_id, created_at, updated_at, user_id, version, project_id, priority, due_at, parent_id, snoozed_until"
+
+// taskTags collects the names of a task's tags into an array
+const taskTags = "ARRAY(SELECT tg.name FROM task_tags tt INNER JOIN tags tg ON tg.id=tt.tag_id WHERE tt.task_id=tasks.id ORDER BY tg.name)"
+
+// commentColumns is the column list every comment query selects, in scanComment order
+const commentColumns = "id, content, created_at, task_id, user_id, version, parent_comment_id, deleted_at IS NOT NULL, updated_at, updated_at > created_at"
+
 var lg = logger.NewFileLogger("logs.log")
 
+// querier is implemented by both the pool and a transaction
+type querier interface {
+	QueryRow(ctx context.Context, sql string, args ...interface{}) pgx.Row
+	Exec(ctx context.Context, sql string, args ...interface{}) (pgconn.CommandTag, error)
+	Query(ctx context.Context, sql string, args ...interface{}) (pgx.Rows, error)
+}
+
 // Service type
 type Service struct {
-	pool *pgxpool.Pool
+	pool     *pgxpool.Pool
+	store    storage.BlobStore
+	webhooks *http.Client
+	changes  *changeBroker
 }
 
 // NewService constructor
-func NewService(pool *pgxpool.Pool) *Service {
-	return &Service{pool: pool}
+func NewService(pool *pgxpool.Pool, store storage.BlobStore) *Service {
+	return &Service{pool: pool, store: store, webhooks: newWebhookClient(), changes: newChangeBroker()}
+}
+
+func scanTask(row pgx.Row, task *models.Task) error {
+	return row.Scan(taskFields(task)...)
+}
+
+// taskFields returns the scan destinations for taskColumns, for queries selecting more columns after them
+func taskFields(task *models.Task) []interface{} {
+	return []interface{}{&task.ID, &task.Title, &task.Description, &task.Tags, &task.StatusID, &task.CreatedAt, &task.UpdatedAt, &task.UserID, &task.Version, &task.ProjectID, &task.Priority, &task.DueAt, &task.ParentID, &task.SnoozedUntil}
+}
+
+func scanComment(row pgx.Row, comment *models.Comment) error {
+	return row.Scan(commentFields(comment)...)
+}
+
+// commentFields returns the scan destinations for commentColumns, for queries selecting more columns after them
+func commentFields(comment *models.Comment) []interface{} {
+	return []interface{}{&comment.ID, &comment.Content, &comment.CreatedAt, &comment.TaskID, &comment.UserID, &comment.Version, &comment.ParentCommentID, &comment.Deleted, &comment.UpdatedAt, &comment.Edited}
 }
 
 // NewUser method
@@ -60,11 +107,22 @@ func (s *Service) NewUser(ctx context.Context, item *models.User) (*models.User,
 
 // NewTask method
 func (s *Service) NewTask(ctx context.Context, item *models.Task, userID int64) (*models.Task, error) {
-	task := &models.Task{}
 	log.Println("Status id", item.StatusID)
 	log.Println("Title", item.Title)
-	err := s.pool.QueryRow(ctx, `INSERT INTO tasks (title, description, tags, status_id, created_at, updated_at, user_id) VALUES ($1, $2, $3, $4, $5, $6, $7) ON CONFLICT DO NOTHING RETURNING id, title, description, tags, status_id, created_at, updated_at, user_id;`, item.Title, item.Description, item.Tags, item.StatusID, item.CreatedAt, item.UpdatedAt, userID).Scan(&task.ID, &task.Title, &task.Description, &task.Tags, &task.StatusID, &task.CreatedAt, &task.UpdatedAt, &task.UserID)
 
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	defer tx.Rollback(ctx)
+
+	task, err := insertTask(ctx, tx, item, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		lg.Error(err)
 		return nil, err
@@ -73,121 +131,261 @@ func (s *Service) NewTask(ctx context.Context, item *models.Task, userID int64)
 	return task, nil
 }
 
-// DeleteTaskByID method
-func (s *Service) DeleteTaskByID(ctx context.Context, id int64, userID int64) (*models.Task, error) {
-	task := &models.Task{}
-	err := s.pool.QueryRow(ctx, `DELETE FROM tasks WHERE id=$1 and user_id=$2 RETURNING *;`, id, userID).Scan(&task.ID, &task.Title, &task.Description, &task.Tags, &task.StatusID, &task.CreatedAt, &task.UpdatedAt, &task.UserID)
+// insertTask creates the task with its tags; q must be a transaction so both land together
+func insertTask(ctx context.Context, q querier, item *models.Task, userID int64) (*models.Task, error) {
+	var id int64
+	err := q.QueryRow(ctx, `INSERT INTO tasks (title, description, status_id, created_at, updated_at, user_id, project_id, priority, due_at, parent_id) VALUES ($1, $2, $3, $4, $5, $6, (SELECT id FROM projects WHERE id=$7 AND user_id=$6), $8, $9, (SELECT id FROM tasks WHERE id=$10 AND user_id=$6)) RETURNING id;`, item.Title, item.Description, item.StatusID, item.CreatedAt, item.UpdatedAt, userID, item.ProjectID, item.Priority, item.DueAt, item.ParentID).Scan(&id)
 
 	if err != nil {
 		lg.Error(err)
-		return nil, ErrNotFound
+		return nil, err
+	}
+
+	err = setTaskTags(ctx, q, id, userID, item.Tags)
+	if err != nil {
+		return nil, err
+	}
+
+	task := &models.Task{}
+	err = scanTask(q.QueryRow(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id=$1;`, id), task)
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+
+	err = recordChange(ctx, q, userID, task.ID, task.ProjectID, WebhookTaskCreated, task)
+	if err != nil {
+		return nil, err
 	}
 
 	return task, nil
 }
 
-// GetAllTasks method
-func (s *Service) GetAllTasks(ctx context.Context, userID int64, tag string, status string, searchText string) ([]*models.Task, error) {
-	items := make([]*models.Task, 0)
-	var query string
-	log.Println("Status:", status)
-	log.Println("Tag:", tag)
-	status = strings.Title(status)
-	tag = strings.ToLower(tag)
-	if len(status) > 0 || len(tag) > 0 {
-		query = fmt.Sprintf("select t.id, t.title, t.description, t.tags, s.id status_id, t.created_at, t.updated_at, t.user_id from tasks t inner join status s on t.status_id=s.id where t.user_id=%d and (s.name='%s' or '%s' = ANY(t.tags));", userID, status, tag)
-	} else if len(searchText) > 0 {
-		log.Println("Search text:", searchText)
-		query = fmt.Sprintf("select * from tasks where description like '%%%s%%' and user_id=%d;", searchText, userID)
-	} else {
-		query = fmt.Sprintf("select * from tasks where user_id=%d", userID)
-	}
-	rows, err := s.pool.Query(ctx, query)
-	if errors.Is(err, sql.ErrNoRows) {
+// DeleteTaskByID method, version 0 deletes unconditionally
+func (s *Service) DeleteTaskByID(ctx context.Context, id int64, userID int64, version int64) (*models.Task, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	task := &models.Task{}
+	err = scanTask(tx.QueryRow(ctx, `DELETE FROM tasks WHERE id=$1 and user_id=$2 and ($3::INT = 0 OR version=$3) RETURNING `+taskColumns+`;`, id, userID, version), task)
+
+	if errors.Is(err, pgx.ErrNoRows) && version != 0 {
+		tx.Rollback(ctx)
+		return s.taskConflict(ctx, id, userID)
+	}
+	if err != nil {
+		lg.Error(err)
 		return nil, ErrNotFound
 	}
+
+	err = recordChange(ctx, tx, userID, task.ID, task.ProjectID, WebhookTaskDeleted, task)
 	if err != nil {
-		log.Print(err)
 		return nil, ErrInternal
 	}
 
-	defer rows.Close()
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
 
-	for rows.Next() {
-		item := &models.Task{}
-		err := rows.Scan(&item.ID, &item.Title, &item.Description, &item.Tags, &item.StatusID, &item.CreatedAt, &item.UpdatedAt, &item.UserID)
-		log.Println(item)
-		if err != nil {
-			log.Print(err)
-			return nil, err
-		}
-		items = append(items, item)
+	return task, nil
+}
+
+// GetAllTasks method, snoozed tasks are left out unless includeSnoozed is set
+func (s *Service) GetAllTasks(ctx context.Context, userID int64, expr filter.Node, includeSnoozed bool, sorting []*models.SortField, page *models.PageRequest) ([]*models.Task, *models.PageInfo, error) {
+	keys, err := taskSortKeys(sorting)
+	if err != nil {
+		return nil, nil, err
 	}
 
-	err = rows.Err()
+	c := &filterSQL{}
+	where := "user_id=" + c.arg(userID)
+	if !includeSnoozed {
+		where += " and (snoozed_until IS NULL or snoozed_until <= NOW()::TIMESTAMP)"
+	}
+	if expr != nil {
+		where += " and (" + c.compile(expr) + ")"
+	}
+
+	rows, info, err := s.listPage(ctx, &pageQuery{
+		columns: taskColumns,
+		from:    "FROM tasks",
+		where:   where,
+		c:       c,
+		keys:    keys,
+	}, page, func() (interface{}, []interface{}) {
+		task := &models.Task{}
+		return task, taskFields(task)
+	})
 	if err != nil {
-		log.Print(err)
-		return nil, err
+		return nil, nil, err
 	}
-	return items, nil
+
+	items := make([]*models.Task, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.(*models.Task))
+	}
+
+	err = s.addChecklistProgress(ctx, items...)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	err = s.addTaskReactions(ctx, userID, items...)
+	if err != nil {
+		return nil, nil, err
+	}
+	return items, info, nil
 }
 
-// UpdateTask method
-func (s *Service) UpdateTask(ctx context.Context, item *models.Task, userID int64) (*models.Task, error) {
-	task := &models.Task{}
-	err := s.pool.QueryRow(ctx, `UPDATE tasks SET description=$1, tags=$2 WHERE id=$3 and user_id=$4 RETURNING *;`, item.Description, item.Tags, item.ID, userID).Scan(&task.ID, &task.Title, &task.Description, &task.Tags, &task.StatusID, &task.CreatedAt, &task.UpdatedAt, &task.UserID)
+// UpdateTask method, version 0 updates unconditionally
+func (s *Service) UpdateTask(ctx context.Context, item *models.Task, userID int64, version int64) (*models.Task, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
 
+	var id int64
+	err = tx.QueryRow(ctx, `UPDATE tasks SET description=$1, updated_at=NOW(), version=version+1 WHERE id=$2 and user_id=$3 and ($4::INT = 0 OR version=$4) RETURNING id;`, item.Description, item.ID, userID, version).Scan(&id)
+
+	if errors.Is(err, pgx.ErrNoRows) && version != 0 {
+		return s.taskConflict(ctx, item.ID, userID)
+	}
 	if err != nil {
 		lg.Error(err)
 		return nil, ErrNotFound
 	}
 
-	return task, nil
-}
+	err = setTaskTags(ctx, tx, id, userID, item.Tags)
+	if err != nil {
+		return nil, ErrInternal
+	}
 
-// MarkAsCompleted method
-func (s *Service) MarkAsCompleted(ctx context.Context, taskID int64, userID int64) (*models.Task, error) {
 	task := &models.Task{}
-	err := s.pool.QueryRow(ctx, `UPDATE tasks SET status_id=1 WHERE id=$1 and user_id=$2 RETURNING *;`, taskID, userID).Scan(&task.ID, &task.Title, &task.Description, &task.Tags, &task.StatusID, &task.CreatedAt, &task.UpdatedAt, &task.UserID)
+	err = scanTask(tx.QueryRow(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id=$1;`, id), task)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
 
+	err = recordChange(ctx, tx, userID, task.ID, task.ProjectID, WebhookTaskUpdated, task)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
 	if err != nil {
 		lg.Error(err)
-		return nil, ErrNotFound
+		return nil, ErrInternal
 	}
 
 	return task, nil
 }
 
+// MarkAsCompleted method
+func (s *Service) MarkAsCompleted(ctx context.Context, taskID int64, userID int64, version int64) (*models.Task, error) {
+	return s.setTaskStatus(ctx, taskID, userID, 1, version, WebhookTaskCompleted)
+}
+
 // MarkAsCanceled method
-func (s *Service) MarkAsCanceled(ctx context.Context, taskID int64, userID int64) (*models.Task, error) {
+func (s *Service) MarkAsCanceled(ctx context.Context, taskID int64, userID int64, version int64) (*models.Task, error) {
+	return s.setTaskStatus(ctx, taskID, userID, 2, version, WebhookTaskCanceled)
+}
+
+func (s *Service) setTaskStatus(ctx context.Context, taskID int64, userID int64, statusID int64, version int64, event string) (*models.Task, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
 	task := &models.Task{}
-	err := s.pool.QueryRow(ctx, `UPDATE tasks SET status_id=2 WHERE id=$1 and user_id=$2 RETURNING *;`, taskID, userID).Scan(&task.ID, &task.Title, &task.Description, &task.Tags, &task.StatusID, &task.CreatedAt, &task.UpdatedAt, &task.UserID)
+	err = scanTask(tx.QueryRow(ctx, `UPDATE tasks SET status_id=$1, updated_at=NOW(), version=version+1 WHERE id=$2 and user_id=$3 and ($4::INT = 0 OR version=$4) RETURNING `+taskColumns+`;`, statusID, taskID, userID, version), task)
 
+	if errors.Is(err, pgx.ErrNoRows) && version != 0 {
+		tx.Rollback(ctx)
+		return s.taskConflict(ctx, taskID, userID)
+	}
 	if err != nil {
 		lg.Error(err)
 		return nil, ErrNotFound
 	}
 
+	err = recordChange(ctx, tx, userID, task.ID, task.ProjectID, event, task)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
 	return task, nil
 }
 
-// AddComment method
+// taskConflict tells a stale version apart from a missing task and returns the current representation
+func (s *Service) taskConflict(ctx context.Context, taskID int64, userID int64) (*models.Task, error) {
+	current, err := s.GetTaskByID(ctx, userID, taskID)
+	if err != nil {
+		return nil, ErrNotFound
+	}
+
+	return current, ErrPreconditionFailed
+}
+
+// AddComment method, a reply names its parent comment and may leave out the task. Mentioned users are notified
 func (s *Service) AddComment(ctx context.Context, item *models.Comment, userID int64) (*models.Comment, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
 	comment := &models.Comment{}
-	err := s.pool.QueryRow(ctx, `INSERT INTO comments (content, task_id, user_id) SELECT $1, $2, $3 FROM tasks WHERE id=$2 AND user_id=$3 ON CONFLICT DO NOTHING RETURNING id, content, created_at, task_id, user_id;`, item.Content, item.TaskID, userID).Scan(&comment.ID, &comment.Content, &comment.CreatedAt, &comment.TaskID, &comment.UserID)
+	err = scanComment(tx.QueryRow(ctx, `INSERT INTO comments (content, task_id, user_id, parent_comment_id)
+		SELECT $1, id, $3, $4 FROM tasks WHERE user_id=$3 AND id=CASE WHEN $4::INT IS NULL THEN $2::INT
+			ELSE (SELECT task_id FROM comments WHERE id=$4 AND deleted_at IS NULL AND ($2::INT = 0 OR task_id=$2::INT) FOR SHARE) END
+		ON CONFLICT DO NOTHING RETURNING `+commentColumns+`;`, item.Content, item.TaskID, userID, item.ParentCommentID), comment)
 
 	if err != nil {
 		lg.Error(err)
 		return nil, ErrNotFound
 	}
 
+	err = syncMentions(ctx, tx, comment)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = recordCommentChange(ctx, tx, WebhookCommentAdded, comment)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
 	return comment, nil
 }
 
 // GetUserInfo method
 func (s *Service) GetUserInfo(ctx context.Context, userID int64) (*models.User, error) {
 	user := &models.User{}
-	err := s.pool.QueryRow(ctx, `SELECT * FROM users WHERE id=$1;`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Hash)
+	err := s.pool.QueryRow(ctx, `SELECT id, username, email, password_hash FROM users WHERE id=$1;`, userID).Scan(&user.ID, &user.Username, &user.Email, &user.Hash)
 
 	if err != nil {
 		lg.Error(err)
@@ -197,39 +395,79 @@ func (s *Service) GetUserInfo(ctx context.Context, userID int64) (*models.User,
 	return user, nil
 }
 
-// GetStatusAndTag method
-func (s *Service) GetStatusAndTag(ctx context.Context, userID int64) ([]*models.TagStatus, error) {
-	items := make([]*models.TagStatus, 0)
-	//query1 := fmt.Sprintf("SELECT * FROM tasks WHERE user_id=%d AND '%s' = ANY(tags)", userID, tag)
-
-	query := fmt.Sprintf("select unnest(t.tags) as tag, s.name from tasks t inner join status s on t.status_id=s.id where t.user_id=%d;", userID)
-
-	rows, err := s.pool.Query(ctx, query)
-	if errors.Is(err, sql.ErrNoRows) {
-		return nil, ErrNotFound
+// GetStatusAndTag counts the tasks of every tag per status in one grouped query
+func (s *Service) GetStatusAndTag(ctx context.Context, userID int64, filter *models.TagStatusFilter) ([]*models.TagStatus, error) {
+	statuses := make([]string, 0)
+	rows, err := s.pool.Query(ctx, `SELECT name FROM status ORDER BY id;`)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	for rows.Next() {
+		var name string
+		err = rows.Scan(&name)
+		if err != nil {
+			rows.Close()
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		statuses = append(statuses, name)
 	}
+	rows.Close()
+	err = rows.Err()
 	if err != nil {
-		log.Print(err)
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	rows, err = s.pool.Query(ctx, `SELECT tg.name, tg.color, s.name, count(*), max(t.updated_at)
+		FROM tasks t
+		INNER JOIN status s ON s.id=t.status_id
+		INNER JOIN task_tags tt ON tt.task_id=t.id
+		INNER JOIN tags tg ON tg.id=tt.tag_id
+		WHERE t.user_id=$1
+		AND ($2::INT IS NULL OR t.project_id=$2)
+		AND ($3::TIMESTAMP IS NULL OR t.updated_at >= $3)
+		AND ($4::TIMESTAMP IS NULL OR t.updated_at < $4)
+		GROUP BY tg.name, tg.color, s.name
+		ORDER BY tg.name;`, userID, filter.ProjectID, filter.From, filter.To)
+	if err != nil {
+		lg.Error(err)
 		return nil, ErrInternal
 	}
 
 	defer rows.Close()
 
+	items := make([]*models.TagStatus, 0)
+	var item *models.TagStatus
 	for rows.Next() {
-		item := &models.TagStatus{}
-		err := rows.Scan(&item.Tag, &item.Status)
-		log.Println(item)
+		var tag, color, status string
+		var count int64
+		var last time.Time
+		err := rows.Scan(&tag, &color, &status, &count, &last)
 		if err != nil {
-			log.Print(err)
-			return nil, err
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+
+		if item == nil || item.Tag != tag {
+			item = &models.TagStatus{Tag: tag, Color: color, Statuses: make(map[string]int64, len(statuses))}
+			for _, name := range statuses {
+				item.Statuses[name] = 0
+			}
+			items = append(items, item)
+		}
+		item.Statuses[status] = count
+		item.Total += count
+		if item.LastActivity == nil || last.After(*item.LastActivity) {
+			item.LastActivity = &last
 		}
-		items = append(items, item)
 	}
 
 	err = rows.Err()
 	if err != nil {
-		log.Print(err)
-		return nil, err
+		lg.Error(err)
+		return nil, ErrInternal
 	}
 	return items, nil
 }
@@ -238,38 +476,162 @@ func (s *Service) GetStatusAndTag(ctx context.Context, userID int64) ([]*models.
 func (s *Service) GetTaskByID(ctx context.Context, userID int64, taskID int64) (*models.Task, error) {
 	item := &models.Task{}
 
-	err := s.pool.QueryRow(ctx, `SELECT * FROM tasks WHERE id=$1 and user_id=$2;`, taskID, userID).Scan(&item.ID, &item.Title, &item.Description, &item.Tags, &item.StatusID, &item.CreatedAt, &item.UpdatedAt, &item.UserID)
+	err := scanTask(s.pool.QueryRow(ctx, `SELECT `+taskColumns+` FROM tasks WHERE id=$1 and user_id=$2;`, taskID, userID), item)
 
 	if err != nil {
 		lg.Error(err)
 		return nil, ErrNotFound
 	}
 
+	err = s.addChecklistProgress(ctx, item)
+	if err != nil {
+		return nil, err
+	}
+
+	err = s.addTaskReactions(ctx, userID, item)
+	if err != nil {
+		return nil, err
+	}
+
 	return item, nil
 }
 
-// DeleteCommentByID method
-func (s *Service) DeleteCommentByID(ctx context.Context, id int64, userID int64) (*models.Comment, error) {
+// GetCommentByID method
+func (s *Service) GetCommentByID(ctx context.Context, id int64, userID int64) (*models.Comment, error) {
+	comment := &models.Comment{}
+	err := scanComment(s.pool.QueryRow(ctx, `SELECT `+commentColumns+` FROM comments WHERE id=$1 and user_id=$2;`, id, userID), comment)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return comment, nil
+}
+
+// DeleteCommentByID method, version 0 deletes unconditionally. A comment with replies is blanked into a tombstone
+// so that its thread stays readable, tombstones left without replies are removed along with their last reply
+func (s *Service) DeleteCommentByID(ctx context.Context, id int64, userID int64, version int64) (*models.Comment, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	// lock the comment first: a reply being added holds a key share on it until it commits, so the check for
+	// replies below sees it, and a reply started after this waits and then finds its parent gone
+	_, err = tx.Exec(ctx, `SELECT 1 FROM comments WHERE id=$1 and user_id=$2 FOR UPDATE;`, id, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
 	comment := &models.Comment{}
-	err := s.pool.QueryRow(ctx, "DELETE FROM comments WHERE id=$1 and user_id=$2 RETURNING *;", id, userID).Scan(&comment.ID, &comment.Content, &comment.CreatedAt, &comment.TaskID, &comment.UserID)
-  
+	err = scanComment(tx.QueryRow(ctx, `UPDATE comments SET content='', deleted_at=NOW(), version=version+1
+		WHERE id=$1 and user_id=$2 and ($3::INT = 0 OR version=$3) and deleted_at IS NULL
+		and EXISTS (SELECT 1 FROM comments r WHERE r.parent_comment_id=comments.id) RETURNING `+commentColumns+`;`, id, userID, version), comment)
+	if errors.Is(err, pgx.ErrNoRows) {
+		err = scanComment(tx.QueryRow(ctx, `DELETE FROM comments WHERE id=$1 and user_id=$2 and ($3::INT = 0 OR version=$3) and deleted_at IS NULL RETURNING `+commentColumns+`;`, id, userID, version), comment)
+	} else if err == nil {
+		_, err = tx.Exec(ctx, `DELETE FROM comment_mentions WHERE comment_id=$1;`, comment.ID)
+		if err == nil {
+			_, err = tx.Exec(ctx, `DELETE FROM comment_reactions WHERE comment_id=$1;`, comment.ID)
+		}
+		if err == nil {
+			_, err = tx.Exec(ctx, `DELETE FROM comment_edits WHERE comment_id=$1;`, comment.ID)
+		}
+	}
+
+	if errors.Is(err, pgx.ErrNoRows) && version != 0 {
+		tx.Rollback(ctx)
+		return s.commentConflict(ctx, id, userID)
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
 	if err != nil {
-	  lg.Error(err)
-	  return nil, ErrNotFound
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	err = recordCommentChange(ctx, tx, WebhookCommentDeleted, comment)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	// a removed reply may leave its tombstoned parents without replies, they go too
+	if !comment.Deleted {
+		err = pruneTombstones(ctx, tx, comment.ParentCommentID)
+		if err != nil {
+			return nil, ErrInternal
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
 	}
-  
+
 	return comment, nil
-  }
+}
+
+// UpdateComment method, version 0 updates unconditionally. A changed content is kept in the comment's edit history
+// and only users newly mentioned by the edit are notified
+func (s *Service) UpdateComment(ctx context.Context, item *models.Comment, userID int64, version int64) (*models.Comment, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `INSERT INTO comment_edits (comment_id, content, version, user_id)
+		SELECT id, content, version, $3 FROM comments WHERE id=$2 and user_id=$3 and ($4::INT = 0 OR version=$4) and deleted_at IS NULL and content<>$1 FOR UPDATE;`, item.Content, item.ID, userID, version)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
 
-  // UpdateComment method
-func (s *Service) UpdateComment(ctx context.Context, item *models.Comment, userID int64) (*models.Comment, error) {
 	comment := &models.Comment{}
-	err := s.pool.QueryRow(ctx, `UPDATE comments SET content=$1 WHERE id=$2 and user_id=$3 RETURNING *;`, item.Content, item.ID, userID).Scan(&comment.ID, &comment.Content, &comment.CreatedAt, &comment.TaskID, &comment.UserID)
+	err = scanComment(tx.QueryRow(ctx, `UPDATE comments SET content=$1, version=version+1, updated_at=CASE WHEN content=$1 THEN updated_at ELSE NOW() END
+		WHERE id=$2 and user_id=$3 and ($4::INT = 0 OR version=$4) and deleted_at IS NULL RETURNING `+commentColumns+`;`, item.Content, item.ID, userID, version), comment)
 
+	if errors.Is(err, pgx.ErrNoRows) && version != 0 {
+		tx.Rollback(ctx)
+		return s.commentConflict(ctx, item.ID, userID)
+	}
 	if err != nil {
 		lg.Error(err)
 		return nil, ErrNotFound
 	}
 
+	err = syncMentions(ctx, tx, comment)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = recordCommentChange(ctx, tx, WebhookCommentUpdated, comment)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
 	return comment, nil
-}
\ No newline at end of file
+}
+
+// commentConflict tells a stale version apart from a missing comment and returns the current representation
+func (s *Service) commentConflict(ctx context.Context, id int64, userID int64) (*models.Comment, error) {
+	current, err := s.GetCommentByID(ctx, id, userID)
+	if err != nil || current.Deleted {
+		return nil, ErrNotFound
+	}
+
+	return current, ErrPreconditionFailed
+}
@@ -0,0 +1,125 @@
+package service
+
+import (
+	"context"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"strconv"
+	"time"
+)
+
+// SnoozeTask hides the task from task listings until the given time
+func (s *Service) SnoozeTask(ctx context.Context, taskID int64, until time.Time, userID int64) (*models.Task, error) {
+	if !until.After(time.Now()) {
+		return nil, ErrInvalidRequest
+	}
+
+	until = until.UTC()
+	return s.setSnooze(ctx, taskID, userID, &until)
+}
+
+// UnsnoozeTask brings the task back right away
+func (s *Service) UnsnoozeTask(ctx context.Context, taskID int64, userID int64) (*models.Task, error) {
+	return s.setSnooze(ctx, taskID, userID, nil)
+}
+
+func (s *Service) setSnooze(ctx context.Context, taskID int64, userID int64, until *time.Time) (*models.Task, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	task := &models.Task{}
+	err = scanTask(tx.QueryRow(ctx, `UPDATE tasks SET snoozed_until=$1, updated_at=NOW(), version=version+1 WHERE id=$2 and user_id=$3 RETURNING `+taskColumns+`;`, until, taskID, userID), task)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	err = recordChange(ctx, tx, userID, task.ID, task.ProjectID, WebhookTaskUpdated, task)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return task, nil
+}
+
+// WakeSnoozedTasks clears every snooze that has passed, records a task.woken event for the owner and a change of
+// the task. The update locks the rows it clears, so a concurrent waker finds them awake and never notifies twice.
+func (s *Service) WakeSnoozedTasks(ctx context.Context) (int64, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	rows, err := tx.Query(ctx, `UPDATE tasks SET snoozed_until=NULL, updated_at=NOW(), version=version+1
+		WHERE snoozed_until <= NOW()::TIMESTAMP RETURNING `+taskColumns+`;`)
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+	woken := make([]*models.Task, 0)
+	for rows.Next() {
+		task := &models.Task{}
+		err = scanTask(rows, task)
+		if err != nil {
+			rows.Close()
+			lg.Error(err)
+			return 0, ErrInternal
+		}
+		woken = append(woken, task)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+
+	for _, task := range woken {
+		_, err = emitEvent(ctx, tx, task.UserID, models.EventTaskWoken, &task.ID, map[string]interface{}{"id": task.ID, "title": task.Title})
+		if err != nil {
+			return 0, ErrInternal
+		}
+		err = recordChange(ctx, tx, task.UserID, task.ID, task.ProjectID, WebhookTaskUpdated, task)
+		if err != nil {
+			return 0, ErrInternal
+		}
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+
+	return int64(len(woken)), nil
+}
+
+// RunSnoozeWaker wakes snoozed tasks every interval until ctx is done
+func (s *Service) RunSnoozeWaker(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			woken, err := s.WakeSnoozedTasks(ctx)
+			if err == nil && woken > 0 {
+				lg.Info("woke snoozed tasks: " + strconv.FormatInt(woken, 10))
+			}
+		}
+	}
+}
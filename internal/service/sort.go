@@ -0,0 +1,100 @@
+package service
+
+import (
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"sort"
+	"strings"
+)
+
+// MaxSortKeys is the largest number of keys a listing can be sorted by
+const MaxSortKeys = 4
+
+// sortField is the SQL a sort key stands for; descExpr replaces expr when sorting descending
+type sortField struct {
+	expr     string
+	descExpr string
+	cast     string
+}
+
+// taskSorts is the whitelist of task sort keys. Expressions are never NULL so that cursors can compare them:
+// tasks without a due date come last in both directions, statuses sort in workflow order.
+var taskSorts = map[string]sortField{
+	"id":         {expr: "id", cast: "INT"},
+	"created_at": {expr: "created_at", cast: "TIMESTAMP"},
+	"updated_at": {expr: "updated_at", cast: "TIMESTAMP"},
+	"title":      {expr: "lower(title)", cast: "TEXT"},
+	"status":     {expr: "CASE status_id WHEN 4 THEN 0 WHEN 3 THEN 1 WHEN 1 THEN 2 ELSE 3 END", cast: "INT"},
+	"priority":   {expr: "priority", cast: "INT"},
+	"due":        {expr: "COALESCE(due_at, 'infinity'::TIMESTAMP)", descExpr: "COALESCE(due_at, '-infinity'::TIMESTAMP)", cast: "TIMESTAMP"},
+	"due_at":     {expr: "COALESCE(due_at, 'infinity'::TIMESTAMP)", descExpr: "COALESCE(due_at, '-infinity'::TIMESTAMP)", cast: "TIMESTAMP"},
+}
+
+// ParseTaskSort reads a sort like "-priority,due" or "priority:desc,due:asc" and checks it against the whitelist
+func ParseTaskSort(value string) ([]*models.SortField, error) {
+	fields := make([]*models.SortField, 0)
+	seen := make(map[string]bool)
+	for _, part := range strings.Split(value, ",") {
+		part = strings.ToLower(strings.TrimSpace(part))
+		if part == "" {
+			continue
+		}
+
+		field := &models.SortField{Field: part}
+		if strings.HasPrefix(part, "-") {
+			field.Field, field.Desc = part[1:], true
+		} else if i := strings.LastIndex(part, ":"); i >= 0 {
+			switch part[i+1:] {
+			case "asc":
+			case "desc":
+				field.Desc = true
+			default:
+				return nil, fmt.Errorf("%w: sort direction must be asc or desc", ErrInvalidRequest)
+			}
+			field.Field = part[:i]
+		}
+
+		sql, ok := taskSorts[field.Field]
+		if !ok {
+			return nil, fmt.Errorf("%w: unknown sort key %q, use one of %s", ErrInvalidRequest, field.Field, strings.Join(taskSortNames(), ", "))
+		}
+		if seen[sql.expr] {
+			return nil, fmt.Errorf("%w: sort key %q given twice", ErrInvalidRequest, field.Field)
+		}
+		seen[sql.expr] = true
+		fields = append(fields, field)
+	}
+	if len(fields) > MaxSortKeys {
+		return nil, fmt.Errorf("%w: at most %d sort keys", ErrInvalidRequest, MaxSortKeys)
+	}
+	return fields, nil
+}
+
+func taskSortNames() []string {
+	names := make([]string, 0, len(taskSorts))
+	for name := range taskSorts {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// taskSortKeys turns sort fields into listing keys, ending with id so the order is total
+func taskSortKeys(fields []*models.SortField) ([]sortKey, error) {
+	keys := make([]sortKey, 0, len(fields)+1)
+	for _, field := range fields {
+		sql, ok := taskSorts[field.Field]
+		if !ok {
+			return nil, ErrInvalidRequest
+		}
+		key := sortKey{expr: sql.expr, cast: sql.cast, desc: field.Desc}
+		if field.Desc && sql.descExpr != "" {
+			key.expr = sql.descExpr
+		}
+		keys = append(keys, key)
+	}
+	if len(keys) == 0 || keys[len(keys)-1].expr != "id" {
+		keys = append(keys, sortKey{expr: "id", cast: "INT"})
+	}
+	return keys, nil
+}
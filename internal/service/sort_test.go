@@ -0,0 +1,105 @@
+package service
+
+import (
+	"errors"
+	"strings"
+	"testing"
+)
+
+func TestParseTaskSort(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"", ""},
+		{" , ", ""},
+		{"priority", "priority"},
+		{"-priority", "-priority"},
+		{"priority:desc", "-priority"},
+		{"priority:asc", "priority"},
+		{"-priority,due", "-priority,due"},
+		{" Priority:DESC , Title ", "-priority,title"},
+		{"status,priority,due,created_at", "status,priority,due,created_at"},
+		{"id", "id"},
+	}
+
+	for _, test := range tests {
+		fields, err := ParseTaskSort(test.value)
+		if err != nil {
+			t.Errorf("ParseTaskSort(%q) error: %v", test.value, err)
+			continue
+		}
+		parts := make([]string, 0, len(fields))
+		for _, field := range fields {
+			if field.Desc {
+				parts = append(parts, "-"+field.Field)
+			} else {
+				parts = append(parts, field.Field)
+			}
+		}
+		if got := strings.Join(parts, ","); got != test.want {
+			t.Errorf("ParseTaskSort(%q) = %s, want %s", test.value, got, test.want)
+		}
+	}
+}
+
+func TestParseTaskSortErrors(t *testing.T) {
+	tests := []struct {
+		value string
+		want  string
+	}{
+		{"password", `unknown sort key "password"`},
+		{"title; DROP TABLE tasks", `unknown sort key "title; drop table tasks"`},
+		{"-", `unknown sort key ""`},
+		{"priority:up", "sort direction must be asc or desc"},
+		{"priority,-priority", `sort key "priority" given twice`},
+		{"due,due_at:desc", `sort key "due_at" given twice`},
+		{"id,title,status,priority,due", "at most 4 sort keys"},
+	}
+
+	for _, test := range tests {
+		_, err := ParseTaskSort(test.value)
+		if !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("ParseTaskSort(%q) error = %v, want ErrInvalidRequest", test.value, err)
+			continue
+		}
+		if !strings.Contains(err.Error(), test.want) {
+			t.Errorf("ParseTaskSort(%q) error %q, want it to mention %q", test.value, err, test.want)
+		}
+	}
+}
+
+func TestTaskSortKeys(t *testing.T) {
+	fields, err := ParseTaskSort("-due,title")
+	if err != nil {
+		t.Fatal(err)
+	}
+	keys, err := taskSortKeys(fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []sortKey{
+		{expr: "COALESCE(due_at, '-infinity'::TIMESTAMP)", cast: "TIMESTAMP", desc: true},
+		{expr: "lower(title)", cast: "TEXT"},
+		{expr: "id", cast: "INT"},
+	}
+	if len(keys) != len(want) {
+		t.Fatalf("keys = %+v, want %+v", keys, want)
+	}
+	for i := range want {
+		if keys[i] != want[i] {
+			t.Errorf("key %d = %+v, want %+v", i, keys[i], want[i])
+		}
+	}
+
+	keys, err = taskSortKeys(nil)
+	if err != nil || len(keys) != 1 || keys[0].expr != "id" {
+		t.Errorf("default keys = %+v, %v", keys, err)
+	}
+
+	fields, _ = ParseTaskSort("priority,-id")
+	keys, _ = taskSortKeys(fields)
+	if len(keys) != 2 || !keys[1].desc {
+		t.Errorf("a trailing id key is not repeated: %+v", keys)
+	}
+}
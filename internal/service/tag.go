@@ -0,0 +1,334 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgconn"
+	"github.com/jackc/pgx/v4"
+	"regexp"
+	"strings"
+)
+
+// ErrConflict if the item clashes with an existing one
+var ErrConflict = errors.New("conflict")
+
+// uniqueViolation is the PostgreSQL error code of a unique constraint violation
+const uniqueViolation = "23505"
+
+// tagColor accepts an empty color or a #rrggbb hex color
+var tagColor = regexp.MustCompile(`^(#[0-9a-fA-F]{6})?$`)
+
+const tagColumns = "tg.id, tg.name, tg.color, tg.description, (SELECT count(*) FROM task_tags WHERE tag_id=tg.id), tg.created_at, tg.user_id"
+
+func scanTag(row pgx.Row, tag *models.Tag) error {
+	return row.Scan(tagFields(tag)...)
+}
+
+func tagFields(tag *models.Tag) []interface{} {
+	return []interface{}{&tag.ID, &tag.Name, &tag.Color, &tag.Description, &tag.TaskCount, &tag.CreatedAt, &tag.UserID}
+}
+
+// normalizeTag is how tag names are stored, so "Work " and "work" are the same tag
+func normalizeTag(name string) string {
+	return strings.ToLower(strings.TrimSpace(name))
+}
+
+func normalizeTags(names []string) []string {
+	seen := make(map[string]bool, len(names))
+	tags := make([]string, 0, len(names))
+	for _, name := range names {
+		name = normalizeTag(name)
+		if name == "" || seen[name] {
+			continue
+		}
+		seen[name] = true
+		tags = append(tags, name)
+	}
+	return tags
+}
+
+func isUniqueViolation(err error) bool {
+	var pgErr *pgconn.PgError
+	return errors.As(err, &pgErr) && pgErr.Code == uniqueViolation
+}
+
+// setTaskTags replaces the tags of a task, creating the user's tags that do not exist yet
+func setTaskTags(ctx context.Context, q querier, taskID int64, userID int64, names []string) error {
+	tags := normalizeTags(names)
+
+	_, err := q.Exec(ctx, `DELETE FROM task_tags WHERE task_id=$1;`, taskID)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	if len(tags) == 0 {
+		return nil
+	}
+
+	_, err = q.Exec(ctx, `INSERT INTO tags (user_id, name) SELECT $1, unnest($2::TEXT[]) ON CONFLICT (user_id, name) DO NOTHING;`, userID, tags)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+
+	_, err = q.Exec(ctx, `INSERT INTO task_tags (task_id, tag_id) SELECT $1, id FROM tags WHERE user_id=$2 and name = ANY($3);`, taskID, userID, tags)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	return nil
+}
+
+// ensureTag returns the id of the user's tag, creating the tag when needed
+func ensureTag(ctx context.Context, q querier, userID int64, name string) (int64, error) {
+	var id int64
+	err := q.QueryRow(ctx, `INSERT INTO tags (user_id, name) VALUES ($1, $2) ON CONFLICT (user_id, name) DO UPDATE SET name=EXCLUDED.name RETURNING id;`, userID, name).Scan(&id)
+	if err != nil {
+		lg.Error(err)
+		return 0, err
+	}
+	return id, nil
+}
+
+// touchTaggedTasks bumps the version of every task carrying one of the tags, since their representation changes,
+// and returns their ids so that the change can be recorded once the tags are settled
+func touchTaggedTasks(ctx context.Context, q querier, tagIDs []int64) ([]int64, error) {
+	rows, err := q.Query(ctx, `UPDATE tasks SET updated_at=NOW(), version=version+1 WHERE id IN (SELECT task_id FROM task_tags WHERE tag_id = ANY($1)) RETURNING id;`, tagIDs)
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+
+	defer rows.Close()
+
+	ids := make([]int64, 0)
+	for rows.Next() {
+		var id int64
+		err = rows.Scan(&id)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		ids = append(ids, id)
+	}
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return ids, nil
+}
+
+// NewTag method
+func (s *Service) NewTag(ctx context.Context, item *models.Tag, userID int64) (*models.Tag, error) {
+	name := normalizeTag(item.Name)
+	if name == "" || !tagColor.MatchString(item.Color) {
+		return nil, ErrInvalidRequest
+	}
+
+	tag := &models.Tag{}
+	err := scanTag(s.pool.QueryRow(ctx, `INSERT INTO tags AS tg (user_id, name, color, description) VALUES ($1, $2, $3, $4) RETURNING `+tagColumns+`;`, userID, name, item.Color, item.Description), tag)
+
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return tag, nil
+}
+
+// GetAllTags method
+func (s *Service) GetAllTags(ctx context.Context, userID int64, page *models.PageRequest) ([]*models.Tag, *models.PageInfo, error) {
+	c := &filterSQL{}
+	rows, info, err := s.listPage(ctx, &pageQuery{
+		columns: tagColumns,
+		from:    "FROM tags tg",
+		where:   "tg.user_id=" + c.arg(userID),
+		c:       c,
+		keys:    []sortKey{{expr: "tg.name", cast: "TEXT"}, {expr: "tg.id", cast: "INT"}},
+	}, page, func() (interface{}, []interface{}) {
+		tag := &models.Tag{}
+		return tag, tagFields(tag)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]*models.Tag, 0, len(rows))
+	for _, row := range rows {
+		items = append(items, row.(*models.Tag))
+	}
+	return items, info, nil
+}
+
+// UpdateTag changes name, color and description; renaming applies to every task at once
+func (s *Service) UpdateTag(ctx context.Context, item *models.Tag, userID int64) (*models.Tag, error) {
+	name := normalizeTag(item.Name)
+	if name == "" || !tagColor.MatchString(item.Color) {
+		return nil, ErrInvalidRequest
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	tag := &models.Tag{}
+	err = scanTag(tx.QueryRow(ctx, `UPDATE tags tg SET name=$1, color=$2, description=$3 WHERE tg.id=$4 and tg.user_id=$5 RETURNING `+tagColumns+`;`, name, item.Color, item.Description, item.ID, userID), tag)
+
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	touched, err := touchTaggedTasks(ctx, tx, []int64{tag.ID})
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = recordTaskChanges(ctx, tx, userID, touched, WebhookTaskUpdated)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return tag, nil
+}
+
+// MergeTags moves the tasks of the source tags onto the target tag and deletes the sources
+func (s *Service) MergeTags(ctx context.Context, item *models.TagMerge, userID int64) (*models.Tag, error) {
+	sources := make([]int64, 0, len(item.SourceIDs))
+	for _, id := range normalizeIDs(item.SourceIDs) {
+		if id != item.TargetID {
+			sources = append(sources, id)
+		}
+	}
+	if len(sources) == 0 {
+		return nil, ErrInvalidRequest
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	var owned int64
+	err = tx.QueryRow(ctx, `SELECT count(*) FROM tags WHERE user_id=$1 and (id = ANY($2) or id=$3);`, userID, sources, item.TargetID).Scan(&owned)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	if owned != int64(len(sources))+1 {
+		return nil, ErrNotFound
+	}
+
+	touched, err := touchTaggedTasks(ctx, tx, append([]int64{item.TargetID}, sources...))
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	_, err = tx.Exec(ctx, `INSERT INTO task_tags (task_id, tag_id) SELECT DISTINCT task_id, $1::INT FROM task_tags WHERE tag_id = ANY($2) ON CONFLICT DO NOTHING;`, item.TargetID, sources)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM tags WHERE user_id=$1 and id = ANY($2);`, userID, sources)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	tag := &models.Tag{}
+	err = scanTag(tx.QueryRow(ctx, `SELECT `+tagColumns+` FROM tags tg WHERE tg.id=$1;`, item.TargetID), tag)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	err = recordTaskChanges(ctx, tx, userID, touched, WebhookTaskUpdated)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return tag, nil
+}
+
+// DeleteTagByID removes the tag from every task and deletes it
+func (s *Service) DeleteTagByID(ctx context.Context, id int64, userID int64) (*models.Tag, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	tag := &models.Tag{}
+	err = scanTag(tx.QueryRow(ctx, `SELECT `+tagColumns+` FROM tags tg WHERE tg.id=$1 and tg.user_id=$2;`, id, userID), tag)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	touched, err := touchTaggedTasks(ctx, tx, []int64{id})
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	_, err = tx.Exec(ctx, `DELETE FROM tags WHERE id=$1;`, id)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	err = recordTaskChanges(ctx, tx, userID, touched, WebhookTaskUpdated)
+	if err != nil {
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return tag, nil
+}
+
+// normalizeIDs drops duplicate ids
+func normalizeIDs(ids []int64) []int64 {
+	seen := make(map[int64]bool, len(ids))
+	result := make([]int64, 0, len(ids))
+	for _, id := range ids {
+		if !seen[id] {
+			seen[id] = true
+			result = append(result, id)
+		}
+	}
+	return result
+}
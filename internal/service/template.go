@@ -0,0 +1,216 @@
+package service
+
+import (
+	"context"
+	"encoding/json"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+	"regexp"
+	"time"
+)
+
+const templateColumns = "id, name, title, description, tags, due_in_days, subtasks, created_at, user_id"
+
+// placeholder matches {{name}} in template texts
+var placeholder = regexp.MustCompile(`\{\{\s*(\w+)\s*\}\}`)
+
+func scanTemplate(row pgx.Row, template *models.Template) error {
+	var subtasks []byte
+	err := row.Scan(&template.ID, &template.Name, &template.Title, &template.Description, &template.Tags, &template.DueInDays, &subtasks, &template.CreatedAt, &template.UserID)
+	if err != nil {
+		return err
+	}
+
+	template.Subtasks = make([]*models.TemplateItem, 0)
+	return json.Unmarshal(subtasks, &template.Subtasks)
+}
+
+func marshalSubtasks(subtasks []*models.TemplateItem) ([]byte, error) {
+	if subtasks == nil {
+		subtasks = make([]*models.TemplateItem, 0)
+	}
+	return json.Marshal(subtasks)
+}
+
+// NewTemplate method
+func (s *Service) NewTemplate(ctx context.Context, item *models.Template, userID int64) (*models.Template, error) {
+	subtasks, err := marshalSubtasks(item.Subtasks)
+	if err != nil {
+		return nil, ErrInvalidRequest
+	}
+
+	template := &models.Template{}
+	err = scanTemplate(s.pool.QueryRow(ctx, `INSERT INTO templates (name, title, description, tags, due_in_days, subtasks, user_id) VALUES ($1, $2, $3, $4, $5, $6::JSONB, $7) RETURNING `+templateColumns+`;`, item.Name, item.Title, item.Description, item.Tags, item.DueInDays, string(subtasks), userID), template)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+
+	return template, nil
+}
+
+// GetAllTemplates method
+func (s *Service) GetAllTemplates(ctx context.Context, userID int64) ([]*models.Template, error) {
+	items := make([]*models.Template, 0)
+	rows, err := s.pool.Query(ctx, `SELECT `+templateColumns+` FROM templates WHERE user_id=$1 ORDER BY id;`, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.Template{}
+		err := scanTemplate(rows, item)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetTemplateByID method
+func (s *Service) GetTemplateByID(ctx context.Context, id int64, userID int64) (*models.Template, error) {
+	template := &models.Template{}
+	err := scanTemplate(s.pool.QueryRow(ctx, `SELECT `+templateColumns+` FROM templates WHERE id=$1 and user_id=$2;`, id, userID), template)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return template, nil
+}
+
+// UpdateTemplate method
+func (s *Service) UpdateTemplate(ctx context.Context, item *models.Template, userID int64) (*models.Template, error) {
+	subtasks, err := marshalSubtasks(item.Subtasks)
+	if err != nil {
+		return nil, ErrInvalidRequest
+	}
+
+	template := &models.Template{}
+	err = scanTemplate(s.pool.QueryRow(ctx, `UPDATE templates SET name=$1, title=$2, description=$3, tags=$4, due_in_days=$5, subtasks=$6::JSONB WHERE id=$7 and user_id=$8 RETURNING `+templateColumns+`;`, item.Name, item.Title, item.Description, item.Tags, item.DueInDays, string(subtasks), item.ID, userID), template)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return template, nil
+}
+
+// DeleteTemplateByID method
+func (s *Service) DeleteTemplateByID(ctx context.Context, id int64, userID int64) (*models.Template, error) {
+	template := &models.Template{}
+	err := scanTemplate(s.pool.QueryRow(ctx, `DELETE FROM templates WHERE id=$1 and user_id=$2 RETURNING `+templateColumns+`;`, id, userID), template)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return template, nil
+}
+
+// InstantiateTemplate creates the template's task and all of its subtasks in one transaction
+func (s *Service) InstantiateTemplate(ctx context.Context, id int64, item *models.Instantiation, userID int64) ([]*models.Task, error) {
+	template, err := s.GetTemplateByID(ctx, id, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	start := time.Now()
+	if item.StartAt != nil {
+		start = *item.StartAt
+	}
+
+	parent, err := fillTemplate(&models.TemplateItem{Title: template.Title, Description: template.Description, Tags: template.Tags, DueInDays: template.DueInDays}, item.Variables, start)
+	if err != nil {
+		return nil, err
+	}
+	parent.ProjectID = item.ProjectID
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	created, err := insertTask(ctx, tx, parent, userID)
+	if err != nil {
+		return nil, ErrInternal
+	}
+	tasks := []*models.Task{created}
+
+	for _, subtask := range template.Subtasks {
+		task, err := fillTemplate(subtask, item.Variables, start)
+		if err != nil {
+			return nil, err
+		}
+		task.ProjectID = item.ProjectID
+		task.ParentID = &created.ID
+
+		child, err := insertTask(ctx, tx, task, userID)
+		if err != nil {
+			return nil, ErrInternal
+		}
+		tasks = append(tasks, child)
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return tasks, nil
+}
+
+// fillTemplate turns a template item into a new task, replacing placeholders and resolving the due offset against start
+func fillTemplate(item *models.TemplateItem, variables map[string]string, start time.Time) (*models.Task, error) {
+	missing := false
+	fill := func(text string) string {
+		return placeholder.ReplaceAllStringFunc(text, func(match string) string {
+			value, ok := variables[placeholder.FindStringSubmatch(match)[1]]
+			if !ok {
+				missing = true
+				return match
+			}
+			return value
+		})
+	}
+
+	now := time.Now()
+	task := &models.Task{
+		Title:       fill(item.Title),
+		Description: fill(item.Description),
+		Tags:        make([]string, 0, len(item.Tags)),
+		StatusID:    4,
+		CreatedAt:   now,
+		UpdatedAt:   now,
+	}
+	for _, tag := range item.Tags {
+		task.Tags = append(task.Tags, fill(tag))
+	}
+	if item.DueInDays != nil {
+		due := start.AddDate(0, 0, int(*item.DueInDays))
+		task.DueAt = &due
+	}
+
+	if missing || len(task.Title) == 0 {
+		return nil, ErrInvalidRequest
+	}
+	return task, nil
+}
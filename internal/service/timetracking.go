@@ -0,0 +1,192 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+	"time"
+)
+
+const timeEntryColumns = "id, task_id, user_id, started_at, ended_at, note, EXTRACT(EPOCH FROM COALESCE(ended_at, NOW()::TIMESTAMP) - started_at)::BIGINT"
+
+// entrySeconds is the tracked time of an entry, counting a running timer up to now
+const entrySeconds = "EXTRACT(EPOCH FROM COALESCE(e.ended_at, NOW()::TIMESTAMP) - e.started_at)::BIGINT"
+
+func scanTimeEntry(row pgx.Row, entry *models.TimeEntry) error {
+	return row.Scan(&entry.ID, &entry.TaskID, &entry.UserID, &entry.StartedAt, &entry.EndedAt, &entry.Note, &entry.Seconds)
+}
+
+// StartTimer starts a timer on the task, stopping the user's running timer if there is one
+func (s *Service) StartTimer(ctx context.Context, taskID int64, userID int64) (*models.TimeEntry, error) {
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	defer tx.Rollback(ctx)
+
+	// concurrent starts of the same user take turns, so the later one stops the timer the earlier one started
+	_, err = tx.Exec(ctx, `SELECT pg_advisory_xact_lock(hashtext('time_entries'), $1);`, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	_, err = tx.Exec(ctx, `UPDATE time_entries SET ended_at=NOW()::TIMESTAMP WHERE user_id=$1 and ended_at IS NULL;`, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	entry := &models.TimeEntry{}
+	err = scanTimeEntry(tx.QueryRow(ctx, `INSERT INTO time_entries (task_id, user_id, started_at) SELECT id, user_id, NOW()::TIMESTAMP FROM tasks WHERE id=$1 and user_id=$2 RETURNING `+timeEntryColumns+`;`, taskID, userID), entry)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return entry, nil
+}
+
+// StopTimer stops the running timer on the task
+func (s *Service) StopTimer(ctx context.Context, taskID int64, userID int64) (*models.TimeEntry, error) {
+	entry := &models.TimeEntry{}
+	err := scanTimeEntry(s.pool.QueryRow(ctx, `UPDATE time_entries SET ended_at=NOW()::TIMESTAMP WHERE task_id=$1 and user_id=$2 and ended_at IS NULL RETURNING `+timeEntryColumns+`;`, taskID, userID), entry)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return entry, nil
+}
+
+// AddTimeEntry records time spent on the task manually
+func (s *Service) AddTimeEntry(ctx context.Context, item *models.TimeEntry, userID int64) (*models.TimeEntry, error) {
+	if item.EndedAt == nil || !item.EndedAt.After(item.StartedAt) {
+		return nil, ErrInvalidRequest
+	}
+
+	entry := &models.TimeEntry{}
+	err := scanTimeEntry(s.pool.QueryRow(ctx, `INSERT INTO time_entries (task_id, user_id, started_at, ended_at, note) SELECT id, user_id, $3, $4, $5 FROM tasks WHERE id=$1 and user_id=$2 RETURNING `+timeEntryColumns+`;`, item.TaskID, userID, item.StartedAt, item.EndedAt, item.Note), entry)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return entry, nil
+}
+
+// DeleteTimeEntryByID method
+func (s *Service) DeleteTimeEntryByID(ctx context.Context, id int64, userID int64) (*models.TimeEntry, error) {
+	entry := &models.TimeEntry{}
+	err := scanTimeEntry(s.pool.QueryRow(ctx, `DELETE FROM time_entries WHERE id=$1 and user_id=$2 RETURNING `+timeEntryColumns+`;`, id, userID), entry)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return entry, nil
+}
+
+// GetTaskTime returns the time entries of a task with their total
+func (s *Service) GetTaskTime(ctx context.Context, taskID int64, userID int64) (*models.TaskTime, error) {
+	_, err := s.GetTaskByID(ctx, userID, taskID)
+	if err != nil {
+		return nil, err
+	}
+
+	rows, err := s.pool.Query(ctx, `SELECT `+timeEntryColumns+` FROM time_entries WHERE task_id=$1 and user_id=$2 ORDER BY started_at;`, taskID, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	result := &models.TaskTime{TaskID: taskID, Entries: make([]*models.TimeEntry, 0)}
+	for rows.Next() {
+		entry := &models.TimeEntry{}
+		err := scanTimeEntry(rows, entry)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		result.Seconds += entry.Seconds
+		result.Entries = append(result.Entries, entry)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return result, nil
+}
+
+// GetTimeReport sums up the time tracked in [from, to) per task, per tag and per day
+func (s *Service) GetTimeReport(ctx context.Context, userID int64, from time.Time, to time.Time) (*models.TimeReport, error) {
+	report := &models.TimeReport{From: from, To: to}
+
+	var err error
+	report.ByTask, err = s.timeTotals(ctx, `SELECT t.title, t.id, SUM(`+entrySeconds+`)::BIGINT FROM time_entries e INNER JOIN tasks t ON t.id=e.task_id
+		WHERE e.user_id=$1 and e.started_at >= $2 and e.started_at < $3 GROUP BY t.id, t.title ORDER BY t.id;`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report.ByTag, err = s.timeTotals(ctx, `SELECT tg.name, NULL::INT, SUM(`+entrySeconds+`)::BIGINT FROM time_entries e INNER JOIN task_tags tt ON tt.task_id=e.task_id INNER JOIN tags tg ON tg.id=tt.tag_id
+		WHERE e.user_id=$1 and e.started_at >= $2 and e.started_at < $3 GROUP BY tg.name ORDER BY tg.name;`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+	report.ByDay, err = s.timeTotals(ctx, `SELECT to_char(e.started_at, 'YYYY-MM-DD') AS day, NULL::INT, SUM(`+entrySeconds+`)::BIGINT FROM time_entries e
+		WHERE e.user_id=$1 and e.started_at >= $2 and e.started_at < $3 GROUP BY day ORDER BY day;`, userID, from, to)
+	if err != nil {
+		return nil, err
+	}
+
+	for _, total := range report.ByDay {
+		report.Seconds += total.Seconds
+	}
+	return report, nil
+}
+
+func (s *Service) timeTotals(ctx context.Context, query string, args ...interface{}) ([]*models.TimeTotal, error) {
+	items := make([]*models.TimeTotal, 0)
+	rows, err := s.pool.Query(ctx, query, args...)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.TimeTotal{}
+		err := rows.Scan(&item.Key, &item.TaskID, &item.Seconds)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return items, nil
+}
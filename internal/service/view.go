@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/filter"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+	"strings"
+	"time"
+)
+
+const viewColumns = "id, name, filter, sort, columns, created_at, updated_at, user_id"
+
+// viewFields are the task fields a view may choose to show
+var viewFields = map[string]bool{
+	"id": true, "title": true, "description": true, "tags": true, "status_id": true, "created_at": true, "updated_at": true,
+	"project_id": true, "priority": true, "due_at": true, "parent_id": true, "snoozed_until": true, "checklist": true,
+}
+
+// smartLists are the built-in views; their relative dates are resolved whenever they run
+var smartLists = []*models.View{
+	{Key: "today", Name: "Today", Filter: "due<tomorrow -status:completed,cancel", Sort: "due,-priority", Smart: true},
+	{Key: "upcoming", Name: "Upcoming", Filter: "due>=tomorrow due<+8d -status:completed,cancel", Sort: "due,-priority", Smart: true},
+	{Key: "overdue", Name: "Overdue", Filter: "due<now -status:completed,cancel", Sort: "due,-priority", Smart: true},
+	{Key: "recently_completed", Name: "Recently Completed", Filter: "status:completed updated>=-7d", Sort: "-updated_at", Smart: true},
+}
+
+func scanView(row pgx.Row, view *models.View) error {
+	return row.Scan(&view.ID, &view.Name, &view.Filter, &view.Sort, &view.Columns, &view.CreatedAt, &view.UpdatedAt, &view.UserID)
+}
+
+// validateView checks the name, that the filter and sort parse, and the columns
+func validateView(item *models.View) error {
+	item.Name = strings.TrimSpace(item.Name)
+	if item.Name == "" {
+		return fmt.Errorf("%w: name required", ErrInvalidRequest)
+	}
+	_, err := filter.Parse(item.Filter, time.Now())
+	if err != nil {
+		return fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	_, err = ParseTaskSort(item.Sort)
+	if err != nil {
+		return err
+	}
+	if item.Columns == nil {
+		item.Columns = make([]string, 0)
+	}
+	for _, column := range item.Columns {
+		if !viewFields[column] {
+			return fmt.Errorf("%w: unknown column %q", ErrInvalidRequest, column)
+		}
+	}
+	return nil
+}
+
+// SmartLists returns the built-in views
+func (s *Service) SmartLists() []*models.View {
+	return smartLists
+}
+
+// SmartList returns the built-in view with the key
+func (s *Service) SmartList(key string) (*models.View, error) {
+	for _, view := range smartLists {
+		if view.Key == key {
+			return view, nil
+		}
+	}
+	return nil, ErrNotFound
+}
+
+// NewView method
+func (s *Service) NewView(ctx context.Context, item *models.View, userID int64) (*models.View, error) {
+	err := validateView(item)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &models.View{}
+	err = scanView(s.pool.QueryRow(ctx, `INSERT INTO views (name, filter, sort, columns, user_id) VALUES ($1, $2, $3, $4, $5) RETURNING `+viewColumns+`;`, item.Name, item.Filter, item.Sort, item.Columns, userID), view)
+
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return view, nil
+}
+
+// GetAllViews method
+func (s *Service) GetAllViews(ctx context.Context, userID int64) ([]*models.View, error) {
+	items := make([]*models.View, 0)
+	rows, err := s.pool.Query(ctx, `SELECT `+viewColumns+` FROM views WHERE user_id=$1 ORDER BY name;`, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.View{}
+		err := scanView(rows, item)
+		if err != nil {
+			lg.Error(err)
+			return nil, err
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, err
+	}
+	return items, nil
+}
+
+// GetViewByID method
+func (s *Service) GetViewByID(ctx context.Context, id int64, userID int64) (*models.View, error) {
+	view := &models.View{}
+	err := scanView(s.pool.QueryRow(ctx, `SELECT `+viewColumns+` FROM views WHERE id=$1 and user_id=$2;`, id, userID), view)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return view, nil
+}
+
+// UpdateView method
+func (s *Service) UpdateView(ctx context.Context, item *models.View, userID int64) (*models.View, error) {
+	err := validateView(item)
+	if err != nil {
+		return nil, err
+	}
+
+	view := &models.View{}
+	err = scanView(s.pool.QueryRow(ctx, `UPDATE views SET name=$1, filter=$2, sort=$3, columns=$4, updated_at=NOW() WHERE id=$5 and user_id=$6 RETURNING `+viewColumns+`;`, item.Name, item.Filter, item.Sort, item.Columns, item.ID, userID), view)
+
+	if isUniqueViolation(err) {
+		return nil, ErrConflict
+	}
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return view, nil
+}
+
+// DeleteViewByID method
+func (s *Service) DeleteViewByID(ctx context.Context, id int64, userID int64) (*models.View, error) {
+	view := &models.View{}
+	err := scanView(s.pool.QueryRow(ctx, `DELETE FROM views WHERE id=$1 and user_id=$2 RETURNING `+viewColumns+`;`, id, userID), view)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return view, nil
+}
+
+// GetViewTasks runs a view's filter and sort through the task listing
+func (s *Service) GetViewTasks(ctx context.Context, userID int64, view *models.View, page *models.PageRequest) ([]*models.Task, *models.PageInfo, error) {
+	expr, err := filter.Parse(view.Filter, time.Now())
+	if err != nil {
+		return nil, nil, fmt.Errorf("%w: %v", ErrInvalidRequest, err)
+	}
+	sorting, err := ParseTaskSort(view.Sort)
+	if err != nil {
+		return nil, nil, err
+	}
+	return s.GetAllTasks(ctx, userID, expr, false, sorting, page)
+}
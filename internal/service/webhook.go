@@ -0,0 +1,555 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"github.com/jackc/pgx/v4"
+	"io"
+	mathrand "math/rand"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+	"syscall"
+	"time"
+)
+
+// Webhook delivery limits
+const (
+	MaxWebhookAttempts   = 8
+	webhookTimeout       = 10 * time.Second
+	webhookBatch         = 20
+	webhookLease         = time.Minute
+	webhookBaseBackoff   = 30 * time.Second
+	webhookMaxBackoff    = 6 * time.Hour
+	webhookResponseLimit = 1024
+	minWebhookSecret     = 16
+)
+
+// Webhook event types a subscription can ask for
+const (
+	WebhookTaskCreated    = "task.created"
+	WebhookTaskUpdated    = "task.updated"
+	WebhookTaskCompleted  = "task.completed"
+	WebhookTaskCanceled   = "task.canceled"
+	WebhookTaskDeleted    = "task.deleted"
+	WebhookCommentAdded   = "comment.added"
+	WebhookCommentUpdated = "comment.updated"
+	WebhookCommentDeleted = "comment.deleted"
+)
+
+var webhookEvents = map[string]bool{
+	WebhookTaskCreated: true, WebhookTaskUpdated: true, WebhookTaskCompleted: true, WebhookTaskCanceled: true, WebhookTaskDeleted: true,
+	WebhookCommentAdded: true, WebhookCommentUpdated: true, WebhookCommentDeleted: true,
+}
+
+// Delivery statuses
+const (
+	deliveryPending   = "pending"
+	deliveryDelivered = "delivered"
+	deliveryFailed    = "failed"
+)
+
+const webhookColumns = "id, url, events, project_id, active, created_at, updated_at, user_id"
+
+const deliveryColumns = "d.id, d.webhook_id, d.event_type, d.payload, d.status, d.attempts, d.next_attempt_at, d.delivered_at, d.created_at"
+
+func scanWebhook(row pgx.Row, webhook *models.Webhook) error {
+	return row.Scan(&webhook.ID, &webhook.URL, &webhook.Events, &webhook.ProjectID, &webhook.Active, &webhook.CreatedAt, &webhook.UpdatedAt, &webhook.UserID)
+}
+
+func deliveryFields(delivery *models.WebhookDelivery) []interface{} {
+	return []interface{}{&delivery.ID, &delivery.WebhookID, &delivery.Event, &delivery.Payload, &delivery.Status, &delivery.Attempts, &delivery.NextAttemptAt, &delivery.DeliveredAt, &delivery.CreatedAt}
+}
+
+// SignWebhook is the signature sent in X-Todo-Signature: the hex HMAC-SHA256 of "<timestamp>.<body>" keyed with the secret.
+// Receivers recompute it and compare in constant time, and reject old timestamps to stop replays
+func SignWebhook(secret string, timestamp int64, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(strconv.FormatInt(timestamp, 10)))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return "sha256=" + hex.EncodeToString(mac.Sum(nil))
+}
+
+// errWebhookAddress is returned for webhook URLs pointing into the server's own network
+var errWebhookAddress = errors.New("webhook address not allowed")
+
+// blockedWebhookIP reports whether ip is loopback, private, link-local, multicast, unspecified or in 0.0.0.0/8:
+// addresses a user must not make the server post to
+func blockedWebhookIP(ip net.IP) bool {
+	if ip4 := ip.To4(); ip4 != nil && ip4[0] == 0 {
+		return true
+	}
+	return ip.IsLoopback() || ip.IsPrivate() || ip.IsLinkLocalUnicast() || ip.IsLinkLocalMulticast() ||
+		ip.IsInterfaceLocalMulticast() || ip.IsMulticast() || ip.IsUnspecified()
+}
+
+// checkWebhookDial is the dialer's Control hook. It sees the address after name resolution, so a host name that
+// resolves to an internal address, even only on a later lookup, is refused too
+func checkWebhookDial(network string, address string, _ syscall.RawConn) error {
+	host, _, err := net.SplitHostPort(address)
+	if err != nil {
+		return err
+	}
+	ip := net.ParseIP(host)
+	if ip == nil || blockedWebhookIP(ip) {
+		return fmt.Errorf("%w: %s", errWebhookAddress, host)
+	}
+	return nil
+}
+
+// newWebhookClient does not follow redirects, ignores proxy settings and only connects to public addresses
+func newWebhookClient() *http.Client {
+	dialer := &net.Dialer{
+		Timeout: webhookTimeout,
+		Control: checkWebhookDial,
+	}
+	return &http.Client{
+		Timeout: webhookTimeout,
+		Transport: &http.Transport{
+			DialContext:         dialer.DialContext,
+			TLSHandshakeTimeout: webhookTimeout,
+			MaxIdleConnsPerHost: 2,
+			IdleConnTimeout:     time.Minute,
+		},
+		CheckRedirect: func(*http.Request, []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+// validateWebhook checks the URL and event types, and generates a secret when none is given on creation
+func validateWebhook(item *models.Webhook, creating bool) error {
+	target, err := url.Parse(strings.TrimSpace(item.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		return fmt.Errorf("%w: url must be an absolute http or https URL", ErrInvalidRequest)
+	}
+	item.URL = target.String()
+	host := strings.ToLower(strings.TrimSuffix(target.Hostname(), "."))
+	if host == "localhost" || strings.HasSuffix(host, ".localhost") {
+		return fmt.Errorf("%w: url must not point to this server", ErrInvalidRequest)
+	}
+	if ip := net.ParseIP(host); ip != nil && blockedWebhookIP(ip) {
+		return fmt.Errorf("%w: url must not point to a private, loopback or link-local address", ErrInvalidRequest)
+	}
+
+	if len(item.Events) == 0 {
+		return fmt.Errorf("%w: at least one event required", ErrInvalidRequest)
+	}
+	for _, event := range item.Events {
+		if !webhookEvents[event] {
+			return fmt.Errorf("%w: unknown event %q", ErrInvalidRequest, event)
+		}
+	}
+
+	if item.Secret == "" && creating {
+		secret := make([]byte, 32)
+		_, err = rand.Read(secret)
+		if err != nil {
+			lg.Error(err)
+			return ErrInternal
+		}
+		item.Secret = hex.EncodeToString(secret)
+	}
+	if item.Secret != "" && len(item.Secret) < minWebhookSecret {
+		return fmt.Errorf("%w: secret must be at least %d characters", ErrInvalidRequest, minWebhookSecret)
+	}
+	return nil
+}
+
+// checkProject makes sure a webhook is only scoped to one of the user's own projects
+func (s *Service) checkProject(ctx context.Context, projectID *int64, userID int64) error {
+	if projectID == nil {
+		return nil
+	}
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM projects WHERE id=$1 and user_id=$2);`, *projectID, userID).Scan(&exists)
+	if err != nil {
+		lg.Error(err)
+		return ErrInternal
+	}
+	if !exists {
+		return fmt.Errorf("%w: unknown project", ErrInvalidRequest)
+	}
+	return nil
+}
+
+// NewWebhook subscribes a URL to events of all the user's tasks, or of one project; it starts active unless told otherwise.
+// The secret is only ever returned here
+func (s *Service) NewWebhook(ctx context.Context, item *models.Webhook, userID int64) (*models.Webhook, error) {
+	err := validateWebhook(item, true)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkProject(ctx, item.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook{}
+	err = scanWebhook(s.pool.QueryRow(ctx, `INSERT INTO webhooks (url, secret, events, project_id, active, user_id) VALUES ($1, $2, $3, $4, COALESCE($5, TRUE), $6) RETURNING `+webhookColumns+`;`,
+		item.URL, item.Secret, item.Events, item.ProjectID, item.Active, userID), webhook)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	webhook.Secret = item.Secret
+	return webhook, nil
+}
+
+// GetAllWebhooks method
+func (s *Service) GetAllWebhooks(ctx context.Context, userID int64) ([]*models.Webhook, error) {
+	items := make([]*models.Webhook, 0)
+	rows, err := s.pool.Query(ctx, `SELECT `+webhookColumns+` FROM webhooks WHERE user_id=$1 ORDER BY id;`, userID)
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	defer rows.Close()
+
+	for rows.Next() {
+		item := &models.Webhook{}
+		err := scanWebhook(rows, item)
+		if err != nil {
+			lg.Error(err)
+			return nil, ErrInternal
+		}
+		items = append(items, item)
+	}
+
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+	return items, nil
+}
+
+// UpdateWebhook method, an empty secret or a missing active flag keep their current value
+func (s *Service) UpdateWebhook(ctx context.Context, item *models.Webhook, userID int64) (*models.Webhook, error) {
+	err := validateWebhook(item, false)
+	if err != nil {
+		return nil, err
+	}
+	err = s.checkProject(ctx, item.ProjectID, userID)
+	if err != nil {
+		return nil, err
+	}
+
+	webhook := &models.Webhook{}
+	err = scanWebhook(s.pool.QueryRow(ctx, `UPDATE webhooks SET url=$1, secret=COALESCE(NULLIF($2, ''), secret), events=$3, project_id=$4, active=COALESCE($5, active), updated_at=NOW()
+		WHERE id=$6 and user_id=$7 RETURNING `+webhookColumns+`;`, item.URL, item.Secret, item.Events, item.ProjectID, item.Active, item.ID, userID), webhook)
+	if errors.Is(err, pgx.ErrNoRows) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return webhook, nil
+}
+
+// DeleteWebhookByID removes the subscription together with its deliveries
+func (s *Service) DeleteWebhookByID(ctx context.Context, id int64, userID int64) (*models.Webhook, error) {
+	webhook := &models.Webhook{}
+	err := scanWebhook(s.pool.QueryRow(ctx, `DELETE FROM webhooks WHERE id=$1 and user_id=$2 RETURNING `+webhookColumns+`;`, id, userID), webhook)
+
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrNotFound
+	}
+
+	return webhook, nil
+}
+
+// GetWebhookDeliveries lists the deliveries of a webhook newest first, each with the log of its attempts
+func (s *Service) GetWebhookDeliveries(ctx context.Context, id int64, userID int64, page *models.PageRequest) ([]*models.WebhookDelivery, *models.PageInfo, error) {
+	var exists bool
+	err := s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhooks WHERE id=$1 and user_id=$2);`, id, userID).Scan(&exists)
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+	if !exists {
+		return nil, nil, ErrNotFound
+	}
+
+	c := &filterSQL{}
+	rows, info, err := s.listPage(ctx, &pageQuery{
+		columns: deliveryColumns,
+		from:    "FROM webhook_deliveries d",
+		where:   "d.webhook_id=" + c.arg(id),
+		c:       c,
+		keys:    []sortKey{{expr: "d.id", cast: "INT", desc: true}},
+	}, page, func() (interface{}, []interface{}) {
+		delivery := &models.WebhookDelivery{}
+		return delivery, deliveryFields(delivery)
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	items := make([]*models.WebhookDelivery, 0, len(rows))
+	byID := make(map[int64]*models.WebhookDelivery, len(rows))
+	ids := make([]int64, 0, len(rows))
+	for _, row := range rows {
+		delivery := row.(*models.WebhookDelivery)
+		delivery.Log = make([]*models.WebhookAttempt, 0)
+		items = append(items, delivery)
+		byID[delivery.ID] = delivery
+		ids = append(ids, delivery.ID)
+	}
+
+	attempts, err := s.pool.Query(ctx, `SELECT delivery_id, id, status_code, error, response_body, duration_ms, attempted_at FROM webhook_attempts WHERE delivery_id = ANY($1) ORDER BY id;`, ids)
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+
+	defer attempts.Close()
+
+	for attempts.Next() {
+		var deliveryID int64
+		attempt := &models.WebhookAttempt{}
+		err := attempts.Scan(&deliveryID, &attempt.ID, &attempt.StatusCode, &attempt.Error, &attempt.ResponseBody, &attempt.DurationMS, &attempt.AttemptedAt)
+		if err != nil {
+			lg.Error(err)
+			return nil, nil, ErrInternal
+		}
+		byID[deliveryID].Log = append(byID[deliveryID].Log, attempt)
+	}
+
+	err = attempts.Err()
+	if err != nil {
+		lg.Error(err)
+		return nil, nil, ErrInternal
+	}
+	return items, info, nil
+}
+
+// RedeliverWebhook queues a delivery again with a fresh set of attempts; its earlier log is kept
+func (s *Service) RedeliverWebhook(ctx context.Context, deliveryID int64, userID int64) (*models.WebhookDelivery, error) {
+	delivery := &models.WebhookDelivery{}
+	err := s.pool.QueryRow(ctx, `UPDATE webhook_deliveries d SET status=$3, attempts=0, next_attempt_at=NOW(), delivered_at=NULL, locked_until=NULL
+		FROM webhooks w WHERE d.id=$1 and w.id=d.webhook_id and w.user_id=$2 and (d.locked_until IS NULL OR d.locked_until < NOW())
+		RETURNING `+deliveryColumns+`;`, deliveryID, userID, deliveryPending).Scan(deliveryFields(delivery)...)
+
+	if errors.Is(err, pgx.ErrNoRows) {
+		var exists bool
+		err = s.pool.QueryRow(ctx, `SELECT EXISTS (SELECT 1 FROM webhook_deliveries d INNER JOIN webhooks w ON w.id=d.webhook_id WHERE d.id=$1 and w.user_id=$2);`, deliveryID, userID).Scan(&exists)
+		if err == nil && exists {
+			return nil, ErrConflict
+		}
+		if err == nil {
+			return nil, ErrNotFound
+		}
+	}
+	if err != nil {
+		lg.Error(err)
+		return nil, ErrInternal
+	}
+
+	return delivery, nil
+}
+
+// enqueueWebhooks queues a delivery of the event for every active subscription of the user that wants it.
+// It runs in the caller's transaction, so an event is queued exactly when its change is committed
+func enqueueWebhooks(ctx context.Context, q querier, userID int64, projectID *int64, event string, data interface{}) error {
+	payload, err := json.Marshal(map[string]interface{}{
+		"event":      event,
+		"created_at": time.Now().UTC(),
+		"data":       data,
+	})
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+
+	_, err = q.Exec(ctx, `INSERT INTO webhook_deliveries (webhook_id, event_type, payload)
+		SELECT id, $2, $3::JSONB FROM webhooks WHERE user_id=$1 and active and $2 = ANY(events) and (project_id IS NULL OR project_id=$4);`,
+		userID, event, string(payload), projectID)
+	if err != nil {
+		lg.Error(err)
+		return err
+	}
+	return nil
+}
+
+// webhookBackoff is the delay before the next attempt: doubling from webhookBaseBackoff up to webhookMaxBackoff, with some jitter
+// so that deliveries failing together do not all retry together
+func webhookBackoff(attempts int64) time.Duration {
+	delay := webhookBaseBackoff
+	for i := int64(1); i < attempts && delay < webhookMaxBackoff; i++ {
+		delay *= 2
+	}
+	if delay > webhookMaxBackoff {
+		delay = webhookMaxBackoff
+	}
+	return delay + time.Duration(mathrand.Int63n(int64(delay)/5+1))
+}
+
+// pendingDelivery is a claimed delivery together with where and how to send it
+type pendingDelivery struct {
+	id       int64
+	event    string
+	payload  []byte
+	attempts int64
+	url      string
+	secret   string
+}
+
+// DeliverWebhooks sends one batch of due deliveries. Deliveries are leased with SKIP LOCKED, so several instances can
+// dispatch side by side, and a lease that runs out with its instance gets picked up again
+func (s *Service) DeliverWebhooks(ctx context.Context) (int64, error) {
+	rows, err := s.pool.Query(ctx, `UPDATE webhook_deliveries d SET locked_until=NOW() + $2 * INTERVAL '1 second'
+		FROM webhooks w
+		WHERE w.id=d.webhook_id and d.id IN (
+			SELECT id FROM webhook_deliveries WHERE status=$3 and next_attempt_at <= NOW() and (locked_until IS NULL OR locked_until < NOW())
+			ORDER BY next_attempt_at LIMIT $1 FOR UPDATE SKIP LOCKED
+		)
+		RETURNING d.id, d.event_type, d.payload::TEXT, d.attempts, w.url, w.secret;`, webhookBatch, int64(webhookLease/time.Second), deliveryPending)
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+
+	batch := make([]*pendingDelivery, 0, webhookBatch)
+	for rows.Next() {
+		delivery := &pendingDelivery{}
+		var payload string
+		err := rows.Scan(&delivery.id, &delivery.event, &payload, &delivery.attempts, &delivery.url, &delivery.secret)
+		if err != nil {
+			rows.Close()
+			lg.Error(err)
+			return 0, ErrInternal
+		}
+		delivery.payload = []byte(payload)
+		batch = append(batch, delivery)
+	}
+	rows.Close()
+	err = rows.Err()
+	if err != nil {
+		lg.Error(err)
+		return 0, ErrInternal
+	}
+
+	var wg sync.WaitGroup
+	for _, delivery := range batch {
+		wg.Add(1)
+		go func(delivery *pendingDelivery) {
+			defer wg.Done()
+			s.recordAttempt(ctx, delivery, sendWebhook(ctx, s.webhooks, delivery))
+		}(delivery)
+	}
+	wg.Wait()
+
+	return int64(len(batch)), nil
+}
+
+// sendWebhook posts one signed delivery and reports how it went
+func sendWebhook(ctx context.Context, client *http.Client, delivery *pendingDelivery) *models.WebhookAttempt {
+	attempt := &models.WebhookAttempt{AttemptedAt: time.Now()}
+
+	request, err := http.NewRequestWithContext(ctx, http.MethodPost, delivery.url, bytes.NewReader(delivery.payload))
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	timestamp := attempt.AttemptedAt.Unix()
+	request.Header.Set("Content-Type", "application/json")
+	request.Header.Set("User-Agent", "TodoList-Webhooks")
+	request.Header.Set("X-Todo-Event", delivery.event)
+	request.Header.Set("X-Todo-Delivery", strconv.FormatInt(delivery.id, 10))
+	request.Header.Set("X-Todo-Timestamp", strconv.FormatInt(timestamp, 10))
+	request.Header.Set("X-Todo-Signature", SignWebhook(delivery.secret, timestamp, delivery.payload))
+
+	response, err := client.Do(request)
+	attempt.DurationMS = time.Since(attempt.AttemptedAt).Milliseconds()
+	if err != nil {
+		attempt.Error = err.Error()
+		return attempt
+	}
+	defer response.Body.Close()
+
+	attempt.StatusCode = &response.StatusCode
+	if response.StatusCode < 200 || response.StatusCode > 299 {
+		// only the status is kept: the body of an error page or redirect is none of the user's business
+		attempt.Error = "unexpected status " + response.Status
+		return attempt
+	}
+	body, _ := io.ReadAll(io.LimitReader(response.Body, webhookResponseLimit))
+	attempt.ResponseBody = strings.ToValidUTF8(string(body), "")
+	return attempt
+}
+
+// recordAttempt logs the attempt and either settles the delivery or schedules its next try
+func (s *Service) recordAttempt(ctx context.Context, delivery *pendingDelivery, attempt *models.WebhookAttempt) {
+	attempts := delivery.attempts + 1
+	status := deliveryPending
+	switch {
+	case attempt.Error == "":
+		status = deliveryDelivered
+	case attempts >= MaxWebhookAttempts:
+		status = deliveryFailed
+	}
+
+	tx, err := s.pool.Begin(ctx)
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+	defer tx.Rollback(ctx)
+
+	_, err = tx.Exec(ctx, `INSERT INTO webhook_attempts (delivery_id, status_code, error, response_body, duration_ms, attempted_at) VALUES ($1, $2, $3, $4, $5, $6);`,
+		delivery.id, attempt.StatusCode, attempt.Error, attempt.ResponseBody, attempt.DurationMS, attempt.AttemptedAt.UTC())
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+	_, err = tx.Exec(ctx, `UPDATE webhook_deliveries SET status=$2, attempts=$3, next_attempt_at=NOW() + $4 * INTERVAL '1 millisecond',
+		delivered_at=CASE WHEN $2='`+deliveryDelivered+`' THEN NOW() END, locked_until=NULL WHERE id=$1;`,
+		delivery.id, status, attempts, webhookBackoff(attempts).Milliseconds())
+	if err != nil {
+		lg.Error(err)
+		return
+	}
+
+	err = tx.Commit(ctx)
+	if err != nil {
+		lg.Error(err)
+	}
+}
+
+// RunWebhookDispatcher delivers due webhooks every interval until ctx is done, right away again while batches come back full
+func (s *Service) RunWebhookDispatcher(ctx context.Context, interval time.Duration) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			for {
+				sent, err := s.DeliverWebhooks(ctx)
+				if err != nil || sent < webhookBatch || ctx.Err() != nil {
+					break
+				}
+			}
+		}
+	}
+}
@@ -0,0 +1,278 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"github.com/AlifAcademy/TodoList/internal/models"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestSignWebhook(t *testing.T) {
+	body := []byte(`{"event":"task.created","data":{"id":1}}`)
+	want := "sha256=e875a6bed5b3f593b44372620ec1b6a89ea1c2fc97b5a86473c2a9c3ec5636cc"
+	if got := SignWebhook("It's a Secret to Everybody", 1700000000, body); got != want {
+		t.Errorf("SignWebhook = %s, want %s", got, want)
+	}
+	if SignWebhook("It's a Secret to Everybody", 1700000001, body) == want {
+		t.Error("the timestamp is not signed")
+	}
+}
+
+func TestValidateWebhookURL(t *testing.T) {
+	for _, address := range []string{
+		"http://localhost:8080/hook",
+		"http://api.localhost/hook",
+		"http://127.0.0.1/hook",
+		"http://127.1.2.3/hook",
+		"http://[::1]/hook",
+		"http://10.0.0.5/hook",
+		"http://172.16.0.1/hook",
+		"http://192.168.1.1/hook",
+		"http://[fd00::1]/hook",
+		"http://169.254.169.254/latest/meta-data",
+		"http://[fe80::1]/hook",
+		"http://0.0.0.0:8080/hook",
+		"http://0.1.2.3/hook",
+		"http://[::]/hook",
+		"http://[::ffff:127.0.0.1]/hook",
+		"http://224.0.0.1/hook",
+		"ftp://example.com/hook",
+		"/hook",
+	} {
+		item := &models.Webhook{URL: address, Events: []string{WebhookTaskCreated}}
+		if err := validateWebhook(item, true); !errors.Is(err, ErrInvalidRequest) {
+			t.Errorf("validateWebhook(%s) = %v, want ErrInvalidRequest", address, err)
+		}
+	}
+
+	for _, address := range []string{"https://example.com/hook", "http://93.184.216.34:8080/hook", "https://hooks.example.com./x"} {
+		item := &models.Webhook{URL: address, Events: []string{WebhookTaskCreated}}
+		if err := validateWebhook(item, true); err != nil {
+			t.Errorf("validateWebhook(%s) = %v", address, err)
+		}
+		if len(item.Secret) != 64 {
+			t.Errorf("generated secret %q", item.Secret)
+		}
+	}
+}
+
+func TestCheckWebhookDial(t *testing.T) {
+	for address, blocked := range map[string]bool{
+		"127.0.0.1:80":         true,
+		"[::1]:443":            true,
+		"10.1.2.3:80":          true,
+		"169.254.169.254:80":   true,
+		"[fe80::1%eth0]:80":    true,
+		"0.0.0.0:80":           true,
+		"93.184.216.34:443":    false,
+		"[2606:4700::1111]:80": false,
+	} {
+		err := checkWebhookDial("tcp", address, nil)
+		if blocked && !errors.Is(err, errWebhookAddress) {
+			t.Errorf("dialing %s = %v, want it refused", address, err)
+		}
+		if !blocked && err != nil {
+			t.Errorf("dialing %s = %v", address, err)
+		}
+	}
+}
+
+// TestWebhookClientRefusesLoopback posts with the real client to a test server, which listens on loopback
+func TestWebhookClientRefusesLoopback(t *testing.T) {
+	hit := false
+	server := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		hit = true
+	}))
+	defer server.Close()
+
+	// a host name resolving to loopback is caught when dialing, not only when validating
+	_, port, _ := net.SplitHostPort(server.Listener.Addr().String())
+	for _, address := range []string{server.URL, "http://localhost:" + port} {
+		attempt := sendWebhook(context.Background(), newWebhookClient(), &pendingDelivery{id: 1, event: WebhookTaskCreated, url: address, secret: "0123456789abcdef"})
+		if !strings.Contains(attempt.Error, errWebhookAddress.Error()) {
+			t.Errorf("posting to %s: error %q", address, attempt.Error)
+		}
+	}
+	if hit {
+		t.Error("the webhook client connected to a loopback address")
+	}
+}
+
+// testWebhookClient is newWebhookClient without the address check, so it can reach httptest servers
+func testWebhookClient() *http.Client {
+	return &http.Client{Timeout: webhookTimeout, CheckRedirect: newWebhookClient().CheckRedirect}
+}
+
+func TestSendWebhook(t *testing.T) {
+	payload := []byte(`{"event":"task.completed","data":{"id":7}}`)
+	var received *http.Request
+	var body []byte
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		received = request
+		body, _ = io.ReadAll(request.Body)
+		writer.Write([]byte("thanks"))
+	}))
+	defer server.Close()
+
+	delivery := &pendingDelivery{id: 42, event: WebhookTaskCompleted, payload: payload, url: server.URL + "/hook", secret: "0123456789abcdef"}
+	attempt := sendWebhook(context.Background(), testWebhookClient(), delivery)
+	if attempt.Error != "" || attempt.StatusCode == nil || *attempt.StatusCode != http.StatusOK {
+		t.Fatalf("attempt = %+v", attempt)
+	}
+	if attempt.ResponseBody != "thanks" {
+		t.Errorf("response body %q", attempt.ResponseBody)
+	}
+
+	if received.Method != http.MethodPost || received.URL.Path != "/hook" {
+		t.Errorf("request %s %s", received.Method, received.URL.Path)
+	}
+	if string(body) != string(payload) {
+		t.Errorf("body %s, want %s", body, payload)
+	}
+	for header, want := range map[string]string{
+		"Content-Type":    "application/json",
+		"X-Todo-Event":    WebhookTaskCompleted,
+		"X-Todo-Delivery": "42",
+	} {
+		if got := received.Header.Get(header); got != want {
+			t.Errorf("%s = %q, want %q", header, got, want)
+		}
+	}
+	timestamp, err := strconv.ParseInt(received.Header.Get("X-Todo-Timestamp"), 10, 64)
+	if err != nil || time.Since(time.Unix(timestamp, 0)) > time.Minute {
+		t.Errorf("X-Todo-Timestamp = %q", received.Header.Get("X-Todo-Timestamp"))
+	}
+	if got, want := received.Header.Get("X-Todo-Signature"), SignWebhook(delivery.secret, timestamp, body); got != want {
+		t.Errorf("X-Todo-Signature = %s, want %s", got, want)
+	}
+}
+
+func TestSendWebhookFailures(t *testing.T) {
+	followed := false
+	target := httptest.NewServer(http.HandlerFunc(func(http.ResponseWriter, *http.Request) {
+		followed = true
+	}))
+	defer target.Close()
+
+	server := httptest.NewServer(http.HandlerFunc(func(writer http.ResponseWriter, request *http.Request) {
+		switch request.URL.Path {
+		case "/error":
+			writer.WriteHeader(http.StatusInternalServerError)
+			writer.Write([]byte("stack trace with internal details"))
+		case "/redirect":
+			http.Redirect(writer, request, target.URL, http.StatusFound)
+		case "/accepted":
+			writer.WriteHeader(http.StatusAccepted)
+		}
+	}))
+	defer server.Close()
+
+	tests := []struct {
+		path   string
+		status int
+		error  string
+	}{
+		{"/accepted", http.StatusAccepted, ""},
+		{"/error", http.StatusInternalServerError, "unexpected status 500 Internal Server Error"},
+		{"/redirect", http.StatusFound, "unexpected status 302 Found"},
+	}
+	for _, test := range tests {
+		attempt := sendWebhook(context.Background(), testWebhookClient(), &pendingDelivery{id: 1, event: WebhookTaskCreated, url: server.URL + test.path, secret: "0123456789abcdef"})
+		if attempt.StatusCode == nil || *attempt.StatusCode != test.status || attempt.Error != test.error {
+			t.Errorf("%s: attempt = %+v", test.path, attempt)
+		}
+		if attempt.ResponseBody != "" {
+			t.Errorf("%s: kept the response body %q", test.path, attempt.ResponseBody)
+		}
+	}
+	if followed {
+		t.Error("the redirect was followed")
+	}
+
+	server.Close()
+	attempt := sendWebhook(context.Background(), testWebhookClient(), &pendingDelivery{id: 1, url: server.URL, secret: "0123456789abcdef"})
+	if attempt.Error == "" || attempt.StatusCode != nil {
+		t.Errorf("unreachable receiver: attempt = %+v", attempt)
+	}
+}
+
+func TestWebhookBackoff(t *testing.T) {
+	for attempts := int64(1); attempts <= MaxWebhookAttempts+4; attempts++ {
+		base := webhookBaseBackoff << uint(attempts-1)
+		if base > webhookMaxBackoff || base <= 0 {
+			base = webhookMaxBackoff
+		}
+		seen := make(map[time.Duration]bool)
+		for i := 0; i < 50; i++ {
+			delay := webhookBackoff(attempts)
+			if delay < base || delay > base+base/5 {
+				t.Fatalf("webhookBackoff(%d) = %v, want between %v and %v", attempts, delay, base, base+base/5)
+			}
+			seen[delay] = true
+		}
+		if len(seen) < 2 {
+			t.Errorf("webhookBackoff(%d) has no jitter", attempts)
+		}
+	}
+}
+
+func TestRecordAttempt(t *testing.T) {
+	s := testService(t)
+	ctx := context.Background()
+	user := testUser(t, s, "hooks")
+	webhook, err := s.NewWebhook(ctx, &models.Webhook{URL: "https://example.com/hook", Events: []string{WebhookTaskCreated}}, user.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	newDelivery := func(attempts int64) *pendingDelivery {
+		delivery := &pendingDelivery{attempts: attempts}
+		err := s.pool.QueryRow(ctx, `INSERT INTO webhook_deliveries (webhook_id, event_type, payload, attempts) VALUES ($1, $2, '{}'::JSONB, $3) RETURNING id;`,
+			webhook.ID, WebhookTaskCreated, attempts).Scan(&delivery.id)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return delivery
+	}
+	status := func(delivery *pendingDelivery) (string, int64, bool) {
+		var value string
+		var attempts int64
+		var delivered bool
+		err := s.pool.QueryRow(ctx, `SELECT status, attempts, delivered_at IS NOT NULL FROM webhook_deliveries WHERE id=$1;`, delivery.id).Scan(&value, &attempts, &delivered)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return value, attempts, delivered
+	}
+	ok, failing := http.StatusOK, http.StatusBadGateway
+
+	tests := []struct {
+		attempts  int64
+		attempt   *models.WebhookAttempt
+		status    string
+		delivered bool
+	}{
+		{0, &models.WebhookAttempt{StatusCode: &ok}, deliveryDelivered, true},
+		{0, &models.WebhookAttempt{StatusCode: &failing, Error: "unexpected status 502 Bad Gateway"}, deliveryPending, false},
+		{MaxWebhookAttempts - 2, &models.WebhookAttempt{Error: "connection refused"}, deliveryPending, false},
+		{MaxWebhookAttempts - 1, &models.WebhookAttempt{Error: "connection refused"}, deliveryFailed, false},
+		{MaxWebhookAttempts - 1, &models.WebhookAttempt{StatusCode: &ok}, deliveryDelivered, true},
+	}
+	for _, test := range tests {
+		delivery := newDelivery(test.attempts)
+		test.attempt.AttemptedAt = time.Now()
+		s.recordAttempt(ctx, delivery, test.attempt)
+
+		got, attempts, delivered := status(delivery)
+		if got != test.status || attempts != test.attempts+1 || delivered != test.delivered {
+			t.Errorf("after attempt %d with %q: %s, %d attempts, delivered %v; want %s", test.attempts+1, test.attempt.Error, got, attempts, delivered, test.status)
+		}
+	}
+}
@@ -0,0 +1,107 @@
+package storage
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"io"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestLocalRoundTrip(t *testing.T) {
+	dir := t.TempDir()
+	store, err := NewLocalStore(filepath.Join(dir, "blobs"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+	key := "ab12cd"
+	content := []byte("attachment content")
+
+	exists, err := store.Exists(ctx, key)
+	if err != nil || exists {
+		t.Fatalf("Exists before Put = %v, %v", exists, err)
+	}
+	_, err = store.Get(ctx, key)
+	if !errors.Is(err, ErrNotFound) {
+		t.Fatalf("Get before Put error = %v, want ErrNotFound", err)
+	}
+
+	err = store.Put(ctx, key, bytes.NewReader(content), int64(len(content)))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = os.Stat(filepath.Join(dir, "blobs", "ab", key))
+	if err != nil {
+		t.Fatalf("blob not stored below its prefix directory: %v", err)
+	}
+	leftovers, _ := filepath.Glob(filepath.Join(dir, "blobs", "ab", ".upload-*"))
+	if len(leftovers) != 0 {
+		t.Fatalf("temporary files left behind: %v", leftovers)
+	}
+
+	exists, err = store.Exists(ctx, key)
+	if err != nil || !exists {
+		t.Fatalf("Exists after Put = %v, %v", exists, err)
+	}
+
+	file, err := store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = file.Seek(11, io.SeekStart)
+	if err != nil {
+		t.Fatal(err)
+	}
+	rest, err := io.ReadAll(file)
+	file.Close()
+	if err != nil || string(rest) != "content" {
+		t.Fatalf("read after seek = %q, %v", rest, err)
+	}
+
+	err = store.Put(ctx, key, bytes.NewReader([]byte("replaced")), 8)
+	if err != nil {
+		t.Fatal(err)
+	}
+	file, err = store.Get(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, _ := io.ReadAll(file)
+	file.Close()
+	if string(got) != "replaced" {
+		t.Fatalf("read after overwrite = %q", got)
+	}
+
+	err = store.Delete(ctx, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = store.Delete(ctx, key)
+	if err != nil {
+		t.Fatalf("deleting a missing blob: %v", err)
+	}
+	exists, err = store.Exists(ctx, key)
+	if err != nil || exists {
+		t.Fatalf("Exists after Delete = %v, %v", exists, err)
+	}
+}
+
+func TestLocalShortKey(t *testing.T) {
+	store, err := NewLocalStore(t.TempDir())
+	if err != nil {
+		t.Fatal(err)
+	}
+	ctx := context.Background()
+
+	err = store.Put(ctx, "ab", bytes.NewReader([]byte("x")), 1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	exists, err := store.Exists(ctx, "ab")
+	if err != nil || !exists {
+		t.Fatalf("Exists = %v, %v", exists, err)
+	}
+}
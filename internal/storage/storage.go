@@ -0,0 +1,46 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"github.com/AlifAcademy/TodoList/config"
+	"io"
+)
+
+// ErrNotFound if a blob does not exist
+var ErrNotFound = errors.New("blob not found")
+
+// BlobStore keeps file contents addressed by key
+type BlobStore interface {
+	// Put stores size bytes read from content under key
+	Put(ctx context.Context, key string, content io.Reader, size int64) error
+	// Get opens the blob for reading; the result supports seeking so it can serve Range requests
+	Get(ctx context.Context, key string) (io.ReadSeekCloser, error)
+	// Exists reports whether a blob is stored under key
+	Exists(ctx context.Context, key string) (bool, error)
+	// Delete removes the blob, deleting a missing blob is not an error
+	Delete(ctx context.Context, key string) error
+}
+
+// NewBlobStore constructor, picks the implementation configured in attachments.store
+func NewBlobStore(cfg config.Config) (BlobStore, error) {
+	switch cfg.GetString("attachments.store") {
+	case "", "local":
+		dir := cfg.GetString("attachments.dir")
+		if dir == "" {
+			dir = "attachments"
+		}
+		return NewLocalStore(dir)
+	case "s3":
+		return NewS3Store(
+			cfg.GetString("attachments.s3.endpoint"),
+			cfg.GetString("attachments.s3.region"),
+			cfg.GetString("attachments.s3.bucket"),
+			cfg.GetString("attachments.s3.access_key"),
+			cfg.GetString("attachments.s3.secret_key"),
+		), nil
+	default:
+		return nil, fmt.Errorf("unknown attachments store %q", cfg.GetString("attachments.store"))
+	}
+}